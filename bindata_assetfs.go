@@ -218,7 +218,7 @@ func htmlJsLaunchcontrolClientJs() (*asset, error) {
 	return a, nil
 }
 
-var _htmlJsLaunchcontrolJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\x92\x3f\x6f\xb3\x30\x10\xc6\x77\x3e\xc5\xc9\x6f\xa4\x18\x05\x19\xbd\x6b\x22\x86\xaa\x5b\x87\x76\xc8\xd0\xd9\x31\x17\x40\x35\x3e\xca\x19\xa2\xa8\xca\x77\xaf\xf8\x6b\x5a\x95\xc9\xcf\xfd\x7c\x8f\x1e\x7c\xb7\x93\x39\x99\xae\x46\xe7\x63\xd5\xa2\xce\xef\xf2\xda\x39\xe3\x2b\x72\xd2\x92\x49\xc0\xd8\x6a\x60\xf0\x15\x01\x00\xec\x3b\x46\x60\xdf\x56\xc6\xef\x4f\xd1\x58\xea\x75\x0b\x86\x9c\x6f\xc9\x32\x64\xb0\x93\x20\xfe\x2d\x5a\x40\xac\x96\xb3\x9c\x1c\x86\xaf\xb1\xfa\x7e\x9c\x9d\x55\x8b\xdc\xd5\x98\x04\xa8\x3b\xc6\x95\x8e\x2a\x40\xf6\xd4\xac\x6c\x10\x01\x59\xd2\x79\x68\xb3\xfa\x3e\xa1\x47\xbc\xc9\x79\xb5\xc8\xa5\xad\x8a\xd2\x2f\x49\x43\x65\xc8\x1a\x94\x8c\x4f\x63\x97\x21\xc7\x64\x51\x59\x2a\xa4\x78\x32\x9f\x5d\xd5\x56\xae\x80\x1b\x5e\x98\xcc\x07\x7a\x31\xdf\x1b\xdc\xad\xee\x9c\x29\xcf\x63\x1d\x32\x70\x78\x83\x77\xbc\x4c\x5a\x8a\x1b\x1f\xd3\x54\xc0\x01\x2c\x19\x55\x12\x7b\x38\x80\x48\xfb\xff\xe9\x4f\xa3\xad\x89\x22\x57\x23\xb3\x2e\x10\x32\x58\xc7\x82\xfd\x66\x20\xbf\x33\x8e\x50\xe5\xda\xeb\xd9\x6f\x09\xa7\xc7\x66\xc8\xe0\xe5\xfc\xf6\xaa\x1a\xdd\x32\xfe\x7d\x39\xbc\xc1\xf6\x39\x44\x4d\x3d\x8a\x64\xb6\x51\x0d\xf1\x7a\xe6\x26\x9f\xdb\x1f\xd1\x63\x58\x1a\x3d\x94\x93\xf9\x4f\xe6\xf1\x3f\x4f\x6b\x14\x9f\xa2\xef\x00\x00\x00\xff\xff\x15\x0b\xe2\x81\x71\x02\x00\x00")
+var _htmlJsLaunchcontrolJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6d\x53\x4d\x6f\xdb\x30\x0c\xbd\xe7\x57\x10\x5a\x81\x2a\x98\x61\x63\xd7\x0c\xc1\x30\xec\xb6\xc3\x7a\xe8\x61\x67\x55\x66\x62\xa3\x8a\xe8\x99\xb2\xd3\x60\xcd\x7f\x1f\x25\x7f\x28\x6d\xa7\x83\x40\xea\x91\x8f\x4f\xa4\x74\xa7\x6b\xb2\xc3\x09\x7d\xd8\x96\x3d\x9a\xfa\xa2\x0f\x83\xb7\xa1\x25\xaf\x1d\xd9\x02\xac\x6b\x23\x06\x7f\x37\x20\xeb\x7e\x60\x04\x0e\x7d\x6b\xc3\xfd\xd7\x4d\x3a\x1a\x4d\x0f\x96\x7c\xe8\xc9\x31\xec\xe1\x4e\x83\xfa\xb4\xf8\x0a\xb6\xe5\x62\xeb\x89\x21\xae\xce\x99\xcb\x6e\x66\x96\xa2\x2c\xe5\x8b\x0c\x1a\xa9\xb1\xa2\xc9\xcb\x20\x07\xea\x56\x2c\x3a\x19\x72\x64\xea\x9c\x26\x15\x26\xe8\xba\xbd\xd1\x79\x70\xc8\x8d\x6b\x8f\x4d\x58\x94\xe6\x93\xa8\x35\x7b\x5a\xd2\x62\x96\xa8\x67\x72\x58\x3a\x3a\x6a\xf5\xdd\xfe\x19\xda\xbe\xf5\x47\x38\xe3\x13\x93\x7d\xc6\xa0\xe6\xb8\xc8\xce\xb6\xc1\x13\x0a\xb3\x34\xae\xec\x7a\x0a\x64\xc9\xc1\x7e\xbf\x07\xd5\x84\xd0\xf1\x4e\xc1\x37\x50\x67\xe6\x5d\x55\x29\xd8\x45\x33\x5a\x99\xc0\x19\x69\x7d\xf3\x98\x88\x85\xc6\xe3\x19\x7e\xe3\xd3\xe4\xeb\x99\xfd\x73\x62\x6f\x88\x83\x98\xaa\x1a\xbf\x54\x6f\x85\xdc\x72\x94\xe4\x4f\xc8\x6c\x8e\x51\xd4\x3a\x56\x1c\x6f\x06\xfa\xfe\x8e\x09\x2c\x6b\x13\xcc\xcc\xb7\x68\x33\x29\x59\x78\x7e\x3e\x3e\xfc\x92\xb1\xf4\x8c\xff\x0f\x6e\x0f\xa0\xa7\xe0\xd2\xbc\xb4\x0c\xaf\xaf\x73\x6e\xf9\xa1\x70\x5c\x55\x05\x0f\xde\x5d\x20\x34\xe9\x61\xd1\x33\x82\x24\x71\x43\x67\x5f\x80\xa7\x90\x00\x92\x4d\x24\xbc\x20\x03\x49\x9f\xcd\x01\xc3\xe5\x3d\xcb\xd8\x92\x33\xb1\x0e\xbf\x41\x7a\x0c\x43\xef\xb3\xbc\xeb\x6a\xe5\x61\xdf\xce\x5d\x9d\x68\x44\x55\x2c\x9a\x3b\xe2\xd5\xe6\xae\x9e\xef\x79\xdd\x5c\xe3\xef\x48\xe5\x8a\xb9\xe5\xf3\x3b\xff\x31\xfd\x17\x09\xfc\x07\x96\xb7\xcd\xc1\x5a\x03\x00\x00")
 
 func htmlJsLaunchcontrolJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -233,7 +233,7 @@ func htmlJsLaunchcontrolJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "html/js/launchcontrol.js", size: 625, mode: os.FileMode(420), modTime: time.Unix(1494513556, 0)}
+	info := bindataFileInfo{name: "html/js/launchcontrol.js", size: 858, mode: os.FileMode(420), modTime: time.Unix(1792415411, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// certificateValidity is the time a generated certificate is valid.
+const certificateValidity = time.Hour * 24 * 365
+
+// createServerTLSConfig returns the TLS configuration for the HTTP server
+// using the certificate and key in PEM format. With selfSigned a certificate
+// is generated for the listen address when the files do not exist or the
+// stored certificate has expired, and stored in them so clients only have to
+// trust it once. Without files the generated certificate is only kept in
+// memory. When only one of the files exists, or only one of them is given, an
+// error is returned instead.
+func createServerTLSConfig(cert, key string, selfSigned bool, listen string) (*tls.Config, error) {
+	if !selfSigned {
+		return loadServerTLSConfig(cert, key)
+	}
+	if (cert == "") != (key == "") {
+		return nil, errors.New("the certificate and key file must both " +
+			"be set to store a self-signed certificate")
+	}
+	if fileExists(cert) != fileExists(key) {
+		exists, missing := cert, key
+		if fileExists(key) {
+			exists, missing = key, cert
+		}
+		return nil, fmt.Errorf("%s exists but %s does not, remove it to "+
+			"generate a new certificate", exists, missing)
+	}
+	if fileExists(cert) {
+		config, err := loadServerTLSConfig(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		if time.Now().Before(leaf.NotAfter) {
+			return config, nil
+		}
+		log.Printf("Self-signed certificate %s expired at %s", cert,
+			leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	certPEM, keyPEM, err := generateCertificate(certificateHosts(listen),
+		time.Now().Add(certificateValidity))
+	if err != nil {
+		return nil, err
+	}
+	if cert != "" {
+		if err := ioutil.WriteFile(cert, certPEM, 0644); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(key, keyPEM, 0600); err != nil {
+			return nil, err
+		}
+		log.Printf("Generated self-signed certificate %s", cert)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{pair}}, nil
+}
+
+// loadServerTLSConfig returns the TLS configuration using the certificate and
+// key files in PEM format.
+func loadServerTLSConfig(cert, key string) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{pair}}, nil
+}
+
+// generateCertificate creates a self-signed certificate and private key in
+// PEM format valid for the given hostnames and IP addresses until notAfter.
+func generateCertificate(hosts []string, notAfter time.Time) (certPEM, keyPEM []byte, err error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Launchcontrol"},
+			CommonName:   hosts[0],
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certificateHosts returns the hostnames and addresses a certificate for the
+// listen address should be valid for.
+func certificateHosts(listen string) []string {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		host = listen
+	}
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name)
+		}
+		if addrs, err := net.InterfaceAddrs(); err == nil {
+			for _, a := range addrs {
+				if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() {
+					hosts = append(hosts, n.IP.String())
+				}
+			}
+		}
+	} else if host != "localhost" && !(ip != nil && ip.IsLoopback()) {
+		hosts = append([]string{host}, hosts...)
+	}
+	return hosts
+}
+
+// fileExists returns true if the file at path exists.
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateServerTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchcontrol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert := filepath.Join(dir, "cert.pem")
+	key := filepath.Join(dir, "key.pem")
+
+	// Without files the certificate is only kept in memory.
+	c, err := createServerTLSConfig("", "", true, "127.0.0.1:6969")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Errorf("generated certificate: %v", err)
+	}
+
+	// Generated certificates are stored and used again.
+	if _, err := createServerTLSConfig(cert, key, true, ":6969"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ioutil.ReadFile(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createServerTLSConfig(cert, key, true, ":6969"); err != nil {
+		t.Fatal(err)
+	}
+	if reused, _ := ioutil.ReadFile(cert); !bytes.Equal(stored, reused) {
+		t.Errorf("stored certificate was replaced")
+	}
+	if _, err := createServerTLSConfig(cert, key, false, ""); err != nil {
+		t.Errorf("load stored certificate: %v", err)
+	}
+
+	// Expired certificates are generated again.
+	certPEM, keyPEM, err := generateCertificate([]string{"localhost"},
+		time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cert, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(key, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = createServerTLSConfig(cert, key, true, ":6969")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err = x509.ParseCertificate(c.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !time.Now().Before(leaf.NotAfter) {
+		t.Errorf("expired certificate used, valid until %s", leaf.NotAfter)
+	}
+	if renewed, _ := ioutil.ReadFile(cert); bytes.Equal(certPEM, renewed) {
+		t.Errorf("expired certificate was not replaced")
+	}
+
+	// A single existing file is not overwritten.
+	if err := os.Remove(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createServerTLSConfig(cert, key, true, ":6969"); err == nil {
+		t.Errorf("only certificate exists, want error")
+	}
+	if fileExists(key) {
+		t.Errorf("key generated while only the certificate existed")
+	}
+
+	// Both files have to be given to store the certificate.
+	missing := filepath.Join(dir, "missing.pem")
+	if _, err := createServerTLSConfig(missing, "", true, ":6969"); err == nil {
+		t.Errorf("only certificate given, want error")
+	}
+	if _, err := createServerTLSConfig("", missing, true, ":6969"); err == nil {
+		t.Errorf("only key given, want error")
+	}
+}
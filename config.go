@@ -0,0 +1,525 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/funjack/launchcontrol/control"
+	"github.com/funjack/launchcontrol/device"
+)
+
+// Config is the configuration file format. Values set on the commandline
+// override the values in the file.
+//
+// Example:
+//	{
+//		"listen": "127.0.0.1:6969",
+//		"tls": {
+//			"cert": "/path/to/cert.pem",
+//			"key": "/path/to/key.pem"
+//		},
+//		"device": {
+//			"buttplug": "wss://localhost:12345/buttplug",
+//			"ca": "/path/to/ca.pem"
+//		},
+//		"personalization": {
+//			"latency": 100,
+//			"speedmax": 70
+//		},
+//		"profiles": "/path/to/profiles.json",
+//		"library": ["/path/to/scripts"],
+//		"limits": {
+//			"maxscriptsize": 1048576,
+//			"loadtimeout": "10s"
+//		},
+//		"auth": {
+//			"enabled": true,
+//			"tokens": "/path/to/tokens.json",
+//			"origins": ["https://example.com"]
+//		}
+//	}
+type Config struct {
+	// Listen is the address the HTTP server listens on.
+	Listen string `json:"listen"`
+	// Server contains the timeouts of the HTTP server.
+	Server ServerConfig `json:"server"`
+	// LiveUDP is the address to listen on for live positions over UDP.
+	LiveUDP string `json:"liveudp"`
+	// TLS contains the HTTPS settings of the HTTP server.
+	TLS TLSConfig `json:"tls"`
+	// Device contains the device backend settings.
+	Device DeviceConfig `json:"device"`
+	// Personalization is the default personalization of scripts.
+	Personalization control.Personalization `json:"personalization"`
+	// Profiles is the file personalization profiles are stored in.
+	Profiles string `json:"profiles"`
+	// Library are the directories scripts are stored in. They are checked
+	// to exist but not used yet, the script library is still to come.
+	Library []string `json:"library"`
+	// Limits restrict the scripts that can be uploaded.
+	Limits LimitsConfig `json:"limits"`
+	// Auth contains the API access settings.
+	Auth AuthConfig `json:"auth"`
+}
+
+// ServerConfig contains the timeouts of the HTTP server (eg "1m".)
+type ServerConfig struct {
+	// ReadTimeout is the time to read a request including the body.
+	ReadTimeout string `json:"readtimeout"`
+	// WriteTimeout is the time to write a response.
+	WriteTimeout string `json:"writetimeout"`
+	// IdleTimeout is the time idle connections are kept open.
+	IdleTimeout string `json:"idletimeout"`
+}
+
+// LimitsConfig contains the limits of uploaded scripts.
+type LimitsConfig struct {
+	// MaxScriptSize is the maximum size of a script in bytes (0 disables.)
+	MaxScriptSize *int `json:"maxscriptsize"`
+	// MaxActions is the maximum number of actions of a loaded script (0
+	// disables.)
+	MaxActions *int `json:"maxactions"`
+	// LoadTimeout is the time to receive and load a script (eg "30s".)
+	LoadTimeout string `json:"loadtimeout"`
+}
+
+// TLSConfig contains the settings to serve HTTPS.
+type TLSConfig struct {
+	// Cert is the certificate in PEM format.
+	Cert string `json:"cert"`
+	// Key is the private key in PEM format.
+	Key string `json:"key"`
+	// SelfSigned generates a self-signed certificate.
+	SelfSigned bool `json:"selfsigned"`
+}
+
+// DeviceConfig contains the settings of the device backends.
+type DeviceConfig struct {
+	// Buttplug is the buttplug.io websocket server address.
+	Buttplug string `json:"buttplug"`
+	// CA is the certificate authority in PEM format.
+	CA string `json:"ca"`
+	// Insecure skips certificate verification.
+	Insecure bool `json:"insecure"`
+	// NoAct simulates a Launch on the console.
+	NoAct bool `json:"noact"`
+	// TCode is the serial port of a T-Code device.
+	TCode string `json:"tcode"`
+	// TCodeBaud is the speed of the T-Code serial port.
+	TCodeBaud int `json:"tcodebaud"`
+	// Vibrate drives vibrators connected to the buttplug server.
+	Vibrate bool `json:"vibrate"`
+	// VibrateCurve is the exponent of the vibration curve.
+	VibrateCurve *float64 `json:"vibratecurve"`
+	// VibrateMax is the maximum vibration intensity.
+	VibrateMax *float64 `json:"vibratemax"`
+	// Park is the position to return to when playback ends (top, bottom,
+	// off or 0-99.)
+	Park string `json:"park"`
+	// ParkSpeed is the speed used to move to the park position.
+	ParkSpeed int `json:"parkspeed"`
+	// Watchdog is the time without heartbeat after which playback is
+	// halted (eg "10s".)
+	Watchdog string `json:"watchdog"`
+	// WatchdogAction is the action taken when the watchdog expires
+	// (pause or stop.)
+	WatchdogAction string `json:"watchdogaction"`
+	// SafetyInterval is the shortest time between two moves (eg "25ms".)
+	SafetyInterval string `json:"safetyinterval"`
+	// SafetyWindow is the period the sustained speed is measured over.
+	SafetyWindow string `json:"safetywindow"`
+	// SafetyMaxSpeed is the highest average speed over the window (0
+	// disables.)
+	SafetyMaxSpeed *int `json:"safetymaxspeed"`
+	// SafetyMin and SafetyMax are the lowest and highest position the
+	// device moves to.
+	SafetyMin *int `json:"safetymin"`
+	SafetyMax *int `json:"safetymax"`
+}
+
+// AuthConfig contains the settings restricting access to the API.
+type AuthConfig struct {
+	// Enabled requires clients to authenticate with a token.
+	Enabled bool `json:"enabled"`
+	// Tokens is the file issued client tokens are stored in.
+	Tokens string `json:"tokens"`
+	// Key is a static token that is always accepted.
+	Key string `json:"key"`
+	// Origins are the origins allowed to use the API from a browser.
+	Origins []string `json:"origins"`
+}
+
+// defaultConfigPath returns the location of the config file that is used
+// when none is specified ($XDG_CONFIG_HOME/launchcontrol/config.json.)
+func defaultConfigPath() string {
+	dir := userConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "launchcontrol", "config.json")
+}
+
+// userConfigDir returns the directory for user configuration files:
+// $XDG_CONFIG_HOME or ~/.config, %AppData% on Windows and ~/Library/Application
+// Support on macOS. An empty string is returned when it is unknown.
+func userConfigDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("AppData")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, "Library", "Application Support")
+		}
+		return ""
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+// loadConfig reads the config file at path. When path is empty the default
+// location is used, which is allowed to not exist.
+func loadConfig(path string) (Config, error) {
+	c := Config{
+		Personalization: control.NewPersonalization(),
+	}
+	if path == "" {
+		path = defaultConfigPath()
+		if _, err := os.Stat(path); path == "" || os.IsNotExist(err) {
+			return c, nil
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// flagValues returns the config values by the name of the flag they
+// correspond with. Empty values are not set in the config.
+func (c Config) flagValues() map[string]string {
+	return map[string]string{
+		"listen":           c.Listen,
+		"read-timeout":     c.Server.ReadTimeout,
+		"write-timeout":    c.Server.WriteTimeout,
+		"idle-timeout":     c.Server.IdleTimeout,
+		"live-udp":         c.LiveUDP,
+		"tls-cert":         c.TLS.Cert,
+		"tls-key":          c.TLS.Key,
+		"tls-self-signed":  strconv.FormatBool(c.TLS.SelfSigned),
+		"buttplug":         c.Device.Buttplug,
+		"ca":               c.Device.CA,
+		"insecure":         strconv.FormatBool(c.Device.Insecure),
+		"noact":            strconv.FormatBool(c.Device.NoAct),
+		"tcode":            c.Device.TCode,
+		"tcode-baud":       formatInt(c.Device.TCodeBaud),
+		"vibrate":          strconv.FormatBool(c.Device.Vibrate),
+		"vibrate-curve":    formatOptionalFloat(c.Device.VibrateCurve),
+		"vibrate-max":      formatOptionalFloat(c.Device.VibrateMax),
+		"park":             c.Device.Park,
+		"park-speed":       formatInt(c.Device.ParkSpeed),
+		"watchdog":         c.Device.Watchdog,
+		"watchdog-action":  c.Device.WatchdogAction,
+		"safety-interval":  c.Device.SafetyInterval,
+		"safety-window":    c.Device.SafetyWindow,
+		"safety-max-speed": formatOptionalInt(c.Device.SafetyMaxSpeed),
+		"safety-min":       formatOptionalInt(c.Device.SafetyMin),
+		"safety-max":       formatOptionalInt(c.Device.SafetyMax),
+		"profiles":         c.Profiles,
+		"max-script-size":  formatOptionalInt(c.Limits.MaxScriptSize),
+		"max-actions":      formatOptionalInt(c.Limits.MaxActions),
+		"load-timeout":     c.Limits.LoadTimeout,
+		"auth":             strconv.FormatBool(c.Auth.Enabled),
+		"tokens":           c.Auth.Tokens,
+		"origins":          strings.Join(c.Auth.Origins, ","),
+	}
+}
+
+// formatInt returns i as string, or an empty string for 0 (not set.)
+func formatInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+// formatOptionalInt returns *i as string, or an empty string when i is nil
+// (not set.) Used for values where 0 is valid, eg to disable a limit.
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+// formatOptionalFloat returns *f as string, or an empty string when f is nil
+// (not set.)
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'g', -1, 64)
+}
+
+// applyConfig sets all flags not specified on the commandline to their value
+// in the config.
+func applyConfig(c Config) error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, value := range c.flagValues() {
+		if set[name] || value == "" {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("config %s: %v", name, err)
+		}
+	}
+	control.DefaultPersonalization = c.Personalization
+	return nil
+}
+
+// merged returns c with every value that has a flag replaced by the value of
+// that flag. After applyConfig this is the configuration in effect, including
+// the values given on the commandline.
+func (c Config) merged() Config {
+	c.Listen = *listen
+	c.Server.ReadTimeout = readTO.String()
+	c.Server.WriteTimeout = writeTO.String()
+	c.Server.IdleTimeout = idleTO.String()
+	c.LiveUDP = *liveUDP
+	c.TLS.Cert = *tlsCert
+	c.TLS.Key = *tlsKey
+	c.TLS.SelfSigned = *tlsSelf
+	c.Device.Buttplug = *buttplug
+	c.Device.CA = *ca
+	c.Device.Insecure = *insecure
+	c.Device.NoAct = *noact
+	c.Device.TCode = *tcode
+	c.Device.TCodeBaud = *baud
+	c.Device.Vibrate = *vibrate
+	c.Device.VibrateCurve = vibCurve
+	c.Device.VibrateMax = vibMax
+	c.Device.Park = *park
+	c.Device.ParkSpeed = *parkSpd
+	c.Device.Watchdog = watchdog.String()
+	c.Device.WatchdogAction = *wdAction
+	c.Device.SafetyInterval = safeIntv.String()
+	c.Device.SafetyWindow = safeWin.String()
+	c.Device.SafetyMaxSpeed = safeSpd
+	c.Device.SafetyMin = safeMin
+	c.Device.SafetyMax = safeMax
+	c.Profiles = *profiles
+	c.Limits.MaxScriptSize = maxSize
+	c.Limits.MaxActions = maxActs
+	c.Limits.LoadTimeout = loadTO.String()
+	c.Auth.Enabled = *auth
+	c.Auth.Tokens = *tokens
+	c.Auth.Origins = nil
+	if *origins != "" {
+		c.Auth.Origins = strings.Split(*origins, ",")
+	}
+	return c
+}
+
+// Validate checks the config for errors and returns all that are found.
+func (c Config) Validate() (errs []error) {
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("listen: %v", err))
+		}
+	}
+	for _, d := range [][2]string{
+		{"server.readtimeout", c.Server.ReadTimeout},
+		{"server.writetimeout", c.Server.WriteTimeout},
+		{"server.idletimeout", c.Server.IdleTimeout},
+		{"limits.loadtimeout", c.Limits.LoadTimeout},
+		{"device.safetyinterval", c.Device.SafetyInterval},
+		{"device.safetywindow", c.Device.SafetyWindow},
+	} {
+		if err := validateDuration(d[0], d[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if v := c.Limits.MaxScriptSize; v != nil && *v < 0 {
+		errs = append(errs, errors.New(
+			"limits.maxscriptsize: must be positive"))
+	}
+	if v := c.Limits.MaxActions; v != nil && *v < 0 {
+		errs = append(errs, errors.New(
+			"limits.maxactions: must be positive"))
+	}
+	if c.LiveUDP != "" {
+		if _, _, err := net.SplitHostPort(c.LiveUDP); err != nil {
+			errs = append(errs, fmt.Errorf("liveudp: %v", err))
+		}
+		if c.Auth.Enabled {
+			// Datagrams carry no token, anyone could take over
+			// the device.
+			errs = append(errs, errors.New(
+				"liveudp: can not be used with auth"))
+		}
+	}
+	if !c.TLS.SelfSigned && (c.TLS.Cert != "" || c.TLS.Key != "") {
+		if _, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %v", err))
+		}
+	}
+	if c.Device.Buttplug != "" {
+		u, err := url.Parse(c.Device.Buttplug)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("device.buttplug: %v", err))
+		} else if u.Scheme != "ws" && u.Scheme != "wss" {
+			errs = append(errs, errors.New(
+				"device.buttplug: scheme must be ws or wss"))
+		}
+	}
+	if c.Device.TCodeBaud < 0 {
+		errs = append(errs, errors.New(
+			"device.tcodebaud: must be positive"))
+	}
+	if v := c.Device.VibrateCurve; v != nil && *v < 0 {
+		errs = append(errs, errors.New(
+			"device.vibratecurve: must be positive"))
+	}
+	if v := c.Device.VibrateMax; v != nil && (*v < 0 || *v > 1) {
+		errs = append(errs, errors.New(
+			"device.vibratemax: must be between 0.0 and 1.0"))
+	}
+	if c.Device.Vibrate && c.Device.Buttplug == "" {
+		errs = append(errs, errors.New(
+			"device.vibrate: requires device.buttplug"))
+	}
+	if c.Device.Park != "" {
+		if _, err := parsePark(c.Device.Park); err != nil {
+			errs = append(errs, fmt.Errorf("device.park: %v", err))
+		}
+	}
+	if c.Device.ParkSpeed < 0 || c.Device.ParkSpeed > 99 {
+		errs = append(errs, errors.New(
+			"device.parkspeed: must be between 1 and 99"))
+	}
+	if err := validateDuration("device.watchdog", c.Device.Watchdog); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Device.WatchdogAction {
+	case "", device.WatchdogPause, device.WatchdogStop:
+	default:
+		errs = append(errs, errors.New(
+			"device.watchdogaction: must be pause or stop"))
+	}
+	if v := c.Device.SafetyMaxSpeed; v != nil && (*v < 0 || *v > 99) {
+		errs = append(errs, errors.New(
+			"device.safetymaxspeed: must be between 0 and 99"))
+	}
+	safeMin := device.DefaultSafetyLimits.PositionMin
+	safeMax := device.DefaultSafetyLimits.PositionMax
+	if c.Device.SafetyMin != nil {
+		safeMin = *c.Device.SafetyMin
+	}
+	if c.Device.SafetyMax != nil {
+		safeMax = *c.Device.SafetyMax
+	}
+	if safeMin < 0 || safeMax > 99 || safeMin >= safeMax {
+		errs = append(errs, errors.New("device.safetymin/safetymax: "+
+			"must be 0-99 with min below max"))
+	}
+	if c.Device.CA != "" {
+		if _, err := loadPEMFile(c.Device.CA); err != nil {
+			errs = append(errs, fmt.Errorf("device.ca: %v", err))
+		}
+	}
+	if err := c.Personalization.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("personalization.%v", err))
+	}
+	if c.Profiles != "" {
+		if _, err := control.NewProfileStore(c.Profiles); err != nil {
+			errs = append(errs, fmt.Errorf("profiles: %v", err))
+		}
+	}
+	for _, dir := range c.Library {
+		if fi, err := os.Stat(dir); err != nil {
+			errs = append(errs, fmt.Errorf("library: %v", err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf(
+				"library: %s is not a directory", dir))
+		}
+	}
+	if c.Auth.Tokens != "" {
+		if _, err := control.NewAuthenticator(c.Auth.Tokens); err != nil {
+			errs = append(errs, fmt.Errorf("auth.tokens: %v", err))
+		}
+	}
+	if c.Auth.Key != "" && len(c.Auth.Key) < 16 {
+		errs = append(errs, errors.New(
+			"auth.key: must be at least 16 characters"))
+	}
+	return errs
+}
+
+// validateDuration returns an error when the duration d of the config value
+// name is invalid or negative. Empty values are not set and valid.
+func validateDuration(name, d string) error {
+	if d == "" {
+		return nil
+	}
+	v, err := time.ParseDuration(d)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	} else if v < 0 {
+		return fmt.Errorf("%s: must be positive", name)
+	}
+	return nil
+}
+
+// configCommand runs the config subcommand and returns the exit code.
+func configCommand(path string, args []string) int {
+	if len(args) != 1 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: launchcontrol [-config file] config check")
+		return 2
+	}
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	c, err := loadConfig(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := applyConfig(c); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	errs := c.merged().Validate()
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: OK\n", path)
+	return 0
+}
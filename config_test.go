@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/funjack/launchcontrol/control"
+)
+
+// resetFlags sets the named flags back to their default value.
+func resetFlags(t *testing.T, names ...string) {
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if err := f.Value.Set(f.DefValue); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeConfig writes config to a temporary file and returns its path and a
+// function to remove it.
+func writeConfig(t *testing.T, config string) (string, func()) {
+	dir, err := ioutil.TempDir("", "launchcontrol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func intp(i int) *int { return &i }
+
+func TestLoadConfig(t *testing.T) {
+	path, remove := writeConfig(t, `{
+		"listen": "127.0.0.1:7000",
+		"limits": {"maxactions": 0},
+		"device": {"safetymaxspeed": 0}
+	}`)
+	defer remove()
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != "127.0.0.1:7000" {
+		t.Errorf("listen, want %q, got %q", "127.0.0.1:7000", c.Listen)
+	}
+	v := c.flagValues()
+	for _, name := range []string{"max-actions", "safety-max-speed"} {
+		if v[name] != "0" {
+			t.Errorf("%s set to 0, want %q, got %q", name, "0", v[name])
+		}
+	}
+	for _, name := range []string{"max-script-size", "safety-min"} {
+		if v[name] != "" {
+			t.Errorf("%s not set, want empty, got %q", name, v[name])
+		}
+	}
+	if c.Personalization != control.NewPersonalization() {
+		t.Errorf("personalization not set, want defaults, got %+v",
+			c.Personalization)
+	}
+
+	unknown, remove := writeConfig(t, `{"lisen": "127.0.0.1:7000"}`)
+	defer remove()
+	if _, err := loadConfig(unknown); err == nil {
+		t.Errorf("unknown field accepted")
+	}
+	missing := filepath.Join(filepath.Dir(unknown), "missing.json")
+	if _, err := loadConfig(missing); err == nil {
+		t.Errorf("missing file accepted")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	defer resetFlags(t, "listen", "park", "max-actions")
+	defer func(p control.Personalization) {
+		control.DefaultPersonalization = p
+	}(control.DefaultPersonalization)
+
+	// Flags given on the commandline take precedence over the config.
+	if err := flag.Set("park", "top"); err != nil {
+		t.Fatal(err)
+	}
+	c := Config{
+		Listen:          "127.0.0.1:7000",
+		Personalization: control.NewPersonalization(),
+	}
+	c.Device.Park = "bottom"
+	c.Limits.MaxActions = intp(0)
+	c.Personalization.Latency = 100
+	if err := applyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if *listen != "127.0.0.1:7000" {
+		t.Errorf("listen, want %q, got %q", "127.0.0.1:7000", *listen)
+	}
+	if *park != "top" {
+		t.Errorf("park from commandline, want %q, got %q", "top", *park)
+	}
+	if *maxActs != 0 {
+		t.Errorf("max-actions, want 0, got %d", *maxActs)
+	}
+	if control.DefaultPersonalization.Latency != 100 {
+		t.Errorf("personalization latency, want 100, got %d",
+			control.DefaultPersonalization.Latency)
+	}
+	if m := c.merged(); m.Listen != *listen || m.Device.Park != *park ||
+		*m.Limits.MaxActions != 0 {
+		t.Errorf("merged config does not match flags: %+v", m)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{Personalization: control.NewPersonalization()}
+	}
+	if errs := valid().Validate(); len(errs) > 0 {
+		t.Errorf("empty config, want no errors, got %v", errs)
+	}
+	if errs := valid().merged().Validate(); len(errs) > 0 {
+		t.Errorf("default flags, want no errors, got %v", errs)
+	}
+	var tests = []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"listen", func(c *Config) { c.Listen = "localhost" }},
+		{"duration", func(c *Config) { c.Server.ReadTimeout = "10" }},
+		{"maxactions", func(c *Config) { c.Limits.MaxActions = intp(-1) }},
+		{"safetymin", func(c *Config) { c.Device.SafetyMin = intp(99) }},
+		{"liveudp", func(c *Config) {
+			c.LiveUDP = "127.0.0.1:6970"
+			c.Auth.Enabled = true
+		}},
+		{"vibrate", func(c *Config) { c.Device.Vibrate = true }},
+		{"key", func(c *Config) { c.Auth.Key = "short" }},
+		{"library", func(c *Config) {
+			c.Library = []string{filepath.Join(os.TempDir(),
+				"launchcontrol-missing")}
+		}},
+		{"personalization", func(c *Config) {
+			c.Personalization.PositionMin = 100
+		}},
+	}
+	for _, tt := range tests {
+		c := valid()
+		tt.modify(&c)
+		if errs := c.Validate(); len(errs) != 1 {
+			t.Errorf("%s, want 1 error, got %v", tt.name, errs)
+		}
+	}
+
+	// Flag values are validated after merging.
+	defer resetFlags(t, "vibrate-max")
+	if err := flag.Lookup("vibrate-max").Value.Set("5"); err != nil {
+		t.Fatal(err)
+	}
+	if errs := valid().merged().Validate(); len(errs) != 1 {
+		t.Errorf("-vibrate-max 5, want 1 error, got %v", errs)
+	}
+}
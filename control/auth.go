@@ -0,0 +1,369 @@
+package control
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PairingTimeout is the time a pairing code stays valid.
+var PairingTimeout = time.Minute * 2
+
+// PairingInterval is the minimum time between two pairing requests from the
+// same address.
+var PairingInterval = time.Second * 10
+
+// pairingAttempts is the number of wrong codes accepted before a pairing
+// request is canceled.
+const pairingAttempts = 3
+
+var (
+	// ErrPairingCode is returned when a pairing code does not match.
+	ErrPairingCode = errors.New("invalid pairing code")
+	// ErrPairingExpired is returned when there is no active pairing for a
+	// client.
+	ErrPairingExpired = errors.New("no pairing in progress")
+	// ErrPairingPending is returned when pairing is requested for a client
+	// that already has a pairing in progress.
+	ErrPairingPending = errors.New("pairing already in progress")
+	// ErrPairingRate is returned when pairing is requested too often.
+	ErrPairingRate = errors.New("too many pairing requests")
+)
+
+// Authenticator restricts access to handlers to clients presenting a known
+// token. Tokens are passed in the Authorization header as bearer token, in the
+// X-API-Key header or as token query parameter (for websockets.)
+//
+// New clients get a token with a pairing flow: the client requests pairing,
+// a code is shown in the server log, and the client exchanges that code for a
+// token.
+type Authenticator struct {
+	sync.Mutex
+
+	path    string
+	tokens  map[string]string // client name by token hash
+	pairing map[string]*pairing
+	started map[string]time.Time // last pairing request by address
+}
+
+// pairing is a pending pairing request.
+type pairing struct {
+	code     string
+	expires  time.Time
+	attempts int
+}
+
+// NewAuthenticator returns an Authenticator using the tokens stored in the
+// JSON file at path. Issued tokens are written to the file. An empty path
+// keeps tokens in memory only.
+func NewAuthenticator(path string) (*Authenticator, error) {
+	a := &Authenticator{
+		path:    path,
+		tokens:  make(map[string]string),
+		pairing: make(map[string]*pairing),
+		started: make(map[string]time.Time),
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &a.tokens); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// AddToken allows access with the given token (eg a static API key.)
+func (a *Authenticator) AddToken(name, token string) {
+	a.Lock()
+	defer a.Unlock()
+	a.tokens[hashToken(token)] = name
+}
+
+// Valid returns true if the token grants access.
+func (a *Authenticator) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	a.Lock()
+	defer a.Unlock()
+	_, ok := a.tokens[hashToken(token)]
+	return ok
+}
+
+// Handler wraps h and only calls it for requests with a valid token. Other
+// requests are answered with 401 Unauthorized. CORS preflight requests are
+// answered with 204 No Content as browsers do not send credentials with them.
+func (a *Authenticator) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Valid(requestToken(r)) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="launchcontrol"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("unauthorized\n"))
+	})
+}
+
+// StartPairing creates a new pairing code for the named client. The code is
+// written to the log for the user to pass on to the client.
+//
+// A client can not restart pairing until its code expires, also not after
+// too many wrong codes.
+func (a *Authenticator) StartPairing(name string) error {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	now := time.Now()
+	if p, ok := a.pairing[name]; ok && now.Before(p.expires) {
+		return ErrPairingPending
+	}
+	p := &pairing{
+		code:    fmt.Sprintf("%06d", n.Int64()),
+		expires: now.Add(PairingTimeout),
+	}
+	a.pairing[name] = p
+	log.Printf("Pairing code for %q: %s", name, p.code)
+	return nil
+}
+
+// limitPairing returns ErrPairingRate when pairing was requested from addr
+// less than PairingInterval ago. The limit is per address so one client can
+// not keep others from pairing.
+func (a *Authenticator) limitPairing(addr string) error {
+	a.Lock()
+	defer a.Unlock()
+	now := time.Now()
+	for k, t := range a.started {
+		if now.Sub(t) >= PairingInterval {
+			delete(a.started, k)
+		}
+	}
+	if _, ok := a.started[addr]; ok {
+		return ErrPairingRate
+	}
+	a.started[addr] = now
+	return nil
+}
+
+// FinishPairing exchanges the pairing code of the named client for a new
+// token.
+func (a *Authenticator) FinishPairing(name, code string) (string, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	p, ok := a.pairing[name]
+	if ok && time.Now().After(p.expires) {
+		delete(a.pairing, name)
+		ok = false
+	}
+	if !ok || p.attempts >= pairingAttempts {
+		// A canceled pairing is kept until it expires so it can not
+		// be restarted right away.
+		return "", ErrPairingExpired
+	}
+	if p.code != code {
+		p.attempts++
+		return "", ErrPairingCode
+	}
+	delete(a.pairing, name)
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	a.tokens[hashToken(token)] = name
+	if err := a.save(); err != nil {
+		delete(a.tokens, hashToken(token))
+		return "", err
+	}
+	log.Printf("Paired client %q", name)
+	return token, nil
+}
+
+// PairHandler is a http.Handler implementing the pairing flow. A POST with
+// only a name parameter starts pairing, a POST with both name and code
+// returns a new token as JSON.
+func (a *Authenticator) PairHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	name, code := r.Form.Get("name"), r.Form.Get("code")
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing client name\n"))
+		return
+	}
+	if code == "" {
+		err := a.limitPairing(remoteHost(r))
+		if err == nil {
+			err = a.StartPairing(name)
+		}
+		switch err {
+		case nil:
+		case ErrPairingPending, ErrPairingRate:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(err.Error() + "\n"))
+			return
+		default:
+			log.Printf("Error starting pairing: %s\n", err)
+			internalServerError(w)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("enter the pairing code shown by launchcontrol\n"))
+		return
+	}
+	token, err := a.FinishPairing(name, code)
+	switch err {
+	case nil:
+		writeJSON(w, struct {
+			Token string `json:"token"`
+		}{token})
+	case ErrPairingCode, ErrPairingExpired:
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error() + "\n"))
+	default:
+		log.Printf("Error finishing pairing: %s\n", err)
+		internalServerError(w)
+	}
+}
+
+// save writes the token hashes to the authenticators file.
+func (a *Authenticator) save() error {
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.tokens, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(a.path), ".tokens")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
+
+// requestToken returns the token presented by a request.
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if h := r.Header.Get("X-API-Key"); h != "" {
+		return h
+	}
+	return r.URL.Query().Get("token")
+}
+
+// remoteHost returns the address of the client without port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hashToken returns the hash of a token as it is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Origins is a list of origins browsers are allowed to make requests from.
+// The origin "*" allows all origins.
+type Origins []string
+
+// Allowed returns true if requests from origin are allowed.
+func (o Origins) Allowed(origin string) bool {
+	for _, allowed := range o {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRequest returns true if r has no Origin header, comes from the
+// same origin as the server or from an allowed origin.
+func (o Origins) allowedRequest(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || o.Allowed(origin)
+}
+
+// Handler wraps h and adds CORS headers for requests from allowed origins.
+// Preflight requests are answered directly. Requests from other origins are
+// answered with 403 Forbidden, browsers send simple requests from any page
+// without asking first.
+func (o Origins) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !o.allowedRequest(r) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("origin not allowed\n"))
+			return
+		}
+		origin := r.Header.Get("Origin")
+		if origin != "" && o.Allowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == "OPTIONS" {
+				w.Header().Set("Access-Control-Allow-Methods",
+					"GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers",
+					"Authorization, Content-Type, X-API-Key")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
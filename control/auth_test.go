@@ -0,0 +1,191 @@
+package control
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticatorPairing(t *testing.T) {
+	a, err := NewAuthenticator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.FinishPairing("kodi", "000000"); err != ErrPairingExpired {
+		t.Errorf("pairing without request, want %v, got %v",
+			ErrPairingExpired, err)
+	}
+	if err := a.StartPairing("kodi"); err != nil {
+		t.Fatal(err)
+	}
+	code := a.pairing["kodi"].code
+	wrong := "1" + code[1:]
+	if code[0] == '1' {
+		wrong = "2" + code[1:]
+	}
+	if _, err := a.FinishPairing("kodi", wrong); err != ErrPairingCode {
+		t.Errorf("wrong code, want %v, got %v", ErrPairingCode, err)
+	}
+	token, err := a.FinishPairing("kodi", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Valid(token) {
+		t.Errorf("issued token is not valid")
+	}
+	if _, err := a.FinishPairing("kodi", code); err != ErrPairingExpired {
+		t.Errorf("code reused, want %v, got %v", ErrPairingExpired, err)
+	}
+}
+
+func TestAuthenticatorPairingLimits(t *testing.T) {
+	a, err := NewAuthenticator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.StartPairing("kodi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.StartPairing("kodi"); err != ErrPairingPending {
+		t.Errorf("restart pending pairing, want %v, got %v",
+			ErrPairingPending, err)
+	}
+
+	code := a.pairing["kodi"].code
+	wrong := "1" + code[1:]
+	if code[0] == '1' {
+		wrong = "2" + code[1:]
+	}
+	for i := 0; i < pairingAttempts; i++ {
+		a.FinishPairing("kodi", wrong)
+	}
+	if _, err := a.FinishPairing("kodi", code); err != ErrPairingExpired {
+		t.Errorf("too many attempts, want %v, got %v", ErrPairingExpired, err)
+	}
+	if err := a.StartPairing("kodi"); err != ErrPairingPending {
+		t.Errorf("restart canceled pairing, want %v, got %v",
+			ErrPairingPending, err)
+	}
+}
+
+func TestPairHandlerRate(t *testing.T) {
+	a, err := NewAuthenticator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		Name, Addr string
+		Status     int
+	}{
+		{"kodi", "192.0.2.1:1000", http.StatusAccepted},
+		{"vlc", "192.0.2.1:1001", http.StatusTooManyRequests},
+		{"vlc", "192.0.2.2:1000", http.StatusAccepted},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest("POST", "/v1/pair?name="+c.Name, nil)
+		r.RemoteAddr = c.Addr
+		w := httptest.NewRecorder()
+		a.PairHandler(w, r)
+		if w.Code != c.Status {
+			t.Errorf("case %d: want status %d, got %d", i, c.Status,
+				w.Code)
+		}
+	}
+}
+
+func TestAuthenticatorHandler(t *testing.T) {
+	a, err := NewAuthenticator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.AddToken("test", "secret")
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		Header, Value, Query string
+		Status               int
+	}{
+		{"", "", "", http.StatusUnauthorized},
+		{"Authorization", "Bearer wrong", "", http.StatusUnauthorized},
+		{"Authorization", "Bearer secret", "", http.StatusOK},
+		{"X-API-Key", "secret", "", http.StatusOK},
+		{"", "", "?token=secret", http.StatusOK},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/play"+c.Query, nil)
+		if c.Header != "" {
+			r.Header.Set(c.Header, c.Value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.Status {
+			t.Errorf("case %d: want status %d, got %d",
+				i, c.Status, w.Code)
+		}
+	}
+}
+
+func TestAuthenticatorPreflight(t *testing.T) {
+	a, err := NewAuthenticator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.AddToken("test", "secret")
+	called := false
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r := httptest.NewRequest("OPTIONS", "/v1/resume", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("want status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if called {
+		t.Errorf("handler called for preflight without token")
+	}
+}
+
+func TestOriginsHandler(t *testing.T) {
+	o := Origins{"https://example.com"}
+	h := o.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest("GET", "/v1/dump", nil)
+	r.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("allowed origin not set, got %q", got)
+	}
+
+	cases := []struct {
+		Origin string
+		Status int
+	}{
+		{"", http.StatusOK},
+		{"http://example.com:6969", http.StatusOK}, // same origin
+		{"https://evil.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r = httptest.NewRequest("GET", "http://example.com:6969/v1/stop", nil)
+		if c.Origin != "" {
+			r.Header.Set("Origin", c.Origin)
+		}
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.Status {
+			t.Errorf("%q: want status %d, got %d", c.Origin, c.Status,
+				w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%q: origin should not be allowed, got %q",
+				c.Origin, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package control
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// bundleMediaType is the media type of script bundles.
+const bundleMediaType = "application/zip"
+
+// Limits protecting against zip bombs.
+const (
+	maxBundleSize      = 32 << 20 // compressed size of the archive
+	maxBundleEntries   = 1024     // number of files in the archive
+	maxBundleEntrySize = 8 << 20  // uncompressed size of a single file
+	maxBundleExtracted = 64 << 20 // uncompressed size of all files read
+)
+
+var (
+	// ErrBundleTooLarge is returned when a bundle exceeds the size limits.
+	ErrBundleTooLarge = errors.New("script bundle too large")
+	// ErrEntryNotFound is returned when the requested entry is not in the
+	// bundle.
+	ErrEntryNotFound = errors.New("entry not found in script bundle")
+)
+
+// bundleMediaTypes maps the filename extensions of scripts in a bundle to
+// their media type.
+var bundleMediaTypes = map[string]string{
+	funscript.Extension: funscriptMediaType,
+	".json":             "application/json",
+	".launch":           "application/prs.launchcontrol+json",
+	".kiiroo":           "text/prs.kiiroo",
+	".meta":             "application/prs.kiiroo+json",
+	".txt":              "text/plain",
+}
+
+// BundleLoader loads a script from a zip archive containing one or more
+// scripts, like they are often distributed.
+//
+// The entry to play can be specified by name. When no entry is specified the
+// best supported entry is played, in the order of the Loaders. Funscripts
+// are loaded together with the scripts for other axes in the archive.
+type BundleLoader struct {
+	Entry string // name of the file in the archive to play
+
+	pers Personalization
+}
+
+// NewBundleLoader returns a BundleLoader that plays entry, or the best
+// supported entry when entry is empty.
+func NewBundleLoader(entry string) *BundleLoader {
+	return &BundleLoader{
+		Entry: entry,
+		pers:  NewPersonalization(),
+	}
+}
+
+// LimitPosition implements the PositionLimiter interface.
+func (b *BundleLoader) LimitPosition(low, high int) {
+	b.pers.PositionMin = low
+	b.pers.PositionMax = high
+}
+
+// LimitSpeed implements the SpeedLimiter interface.
+func (b *BundleLoader) LimitSpeed(slow, fast int) {
+	b.pers.SpeedMin = slow
+	b.pers.SpeedMax = fast
+}
+
+// Load returns a player for the selected script in the zip archive read
+// from r.
+func (b *BundleLoader) Load(r io.Reader) (protocol.Player, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBundleSize {
+		return nil, ErrBundleTooLarge
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if len(zr.File) > maxBundleEntries {
+		return nil, ErrBundleTooLarge
+	}
+	bundle := &bundle{
+		files:  zr.File,
+		budget: maxBundleExtracted,
+		pers:   b.pers,
+	}
+	if b.Entry != "" {
+		for _, f := range zr.File {
+			if f.Name == b.Entry {
+				return bundle.load(f)
+			}
+		}
+		return nil, ErrEntryNotFound
+	}
+	for _, f := range bundle.candidates() {
+		p, err := bundle.load(f)
+		if err == ErrBundleTooLarge {
+			return nil, err
+		} else if err == nil {
+			return p, nil
+		}
+	}
+	return nil, ErrUnsupported
+}
+
+// LoadBundle loads the script named entry from the zip archive in r.
+func LoadBundle(r io.Reader, entry string, p Personalization) (protocol.Player, error) {
+	sp, err := load(Loader{
+		Name:         "zip",
+		Loader:       NewBundleLoader(entry),
+		ContentTypes: []string{bundleMediaType},
+	}, r, p)
+	if err != nil {
+		scriptLoadFailuresTotal.Inc()
+	}
+	return sp, err
+}
+
+// bundle is an opened zip archive of scripts.
+type bundle struct {
+	files  []*zip.File
+	budget int64 // bytes that can still be extracted
+	pers   Personalization
+}
+
+// candidates returns the files that contain a supported script type, in
+// order of preference.
+func (b *bundle) candidates() []*zip.File {
+	var files []*zip.File
+	for _, f := range b.files {
+		if isBundleScript(f.Name) {
+			files = append(files, f)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return bundleRank(files[i].Name) < bundleRank(files[j].Name)
+	})
+	return files
+}
+
+// load returns a player for the script in f. Funscripts are combined with
+// the other axes of the same set.
+func (b *bundle) load(f *zip.File) (protocol.Player, error) {
+	mediaType := bundleMediaTypes[strings.ToLower(path.Ext(f.Name))]
+	if mediaType == funscriptMediaType {
+		return b.loadFunscriptSet(f)
+	}
+	data, err := b.read(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range Loaders {
+		if isBundleLoader(l) {
+			continue
+		}
+		if mediaType != "" && !l.IsSupported(mediaType) {
+			continue
+		}
+		lp := personalizedLoader(l.Loader, b.pers)
+		if p, err := lp.Load(bytes.NewReader(data)); err == nil {
+			log.Printf("Loaded %s script from bundle: %s", l.Name, f.Name)
+			return p, nil
+		}
+	}
+	return nil, ErrUnsupported
+}
+
+// loadFunscriptSet loads the Funscript f together with the scripts of the
+// other axes in its set.
+func (b *bundle) loadFunscriptSet(f *zip.File) (protocol.Player, error) {
+	name, _ := funscript.SetName(f.Name)
+	var set funscriptSet
+	for _, o := range b.files {
+		if n, ok := funscript.SetName(o.Name); !ok || n != name {
+			continue
+		}
+		data, err := b.read(o)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.add(o.Name, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+	r, err := set.reader()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range Loaders {
+		if !l.IsSupported(funscriptMediaType) {
+			continue
+		}
+		p, err := personalizedLoader(l.Loader, b.pers).Load(r)
+		if err == nil {
+			log.Printf("Loaded %s script set from bundle: %s", l.Name,
+				name)
+		}
+		return p, err
+	}
+	return nil, ErrUnsupported
+}
+
+// read returns the uncompressed content of f.
+func (b *bundle) read(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxBundleEntrySize {
+		return nil, ErrBundleTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// Do not trust the size in the header
+	data, err := ioutil.ReadAll(io.LimitReader(rc, maxBundleEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	b.budget -= int64(len(data))
+	if len(data) > maxBundleEntrySize || b.budget < 0 {
+		return nil, ErrBundleTooLarge
+	}
+	return data, nil
+}
+
+// isBundleScript returns true if name is a file with a script extension.
+// Directories and metadata files added by archivers are skipped.
+func isBundleScript(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") ||
+		strings.HasPrefix(path.Base(name), "._") {
+		return false
+	}
+	_, ok := bundleMediaTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// bundleRank returns the preference of a file in a bundle, lower is better.
+// Files are ranked on the first loader that supports them, and files that
+// have the main media type of that loader before files that are merely
+// supported by it.
+func bundleRank(name string) int {
+	mediaType := bundleMediaTypes[strings.ToLower(path.Ext(name))]
+	for i, l := range Loaders {
+		if !l.IsSupported(mediaType) {
+			continue
+		}
+		if strings.EqualFold(l.ContentTypes[0], mediaType) {
+			return 2 * i
+		}
+		return 2*i + 1
+	}
+	return 2 * len(Loaders)
+}
+
+// isBundleLoader returns true if l loads bundles, bundles in bundles are not
+// supported.
+func isBundleLoader(l Loader) bool {
+	_, ok := l.Loader.(*BundleLoader)
+	return ok
+}
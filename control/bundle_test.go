@@ -0,0 +1,132 @@
+package control
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+const (
+	bundleFunscript = `{"actions":[{"at":100,"pos":0},{"at":600,"pos":100}]}`
+	bundleRoll      = `{"actions":[{"at":300,"pos":50},{"at":500,"pos":100}]}`
+	bundleKiiroo    = `{1.00:1,2.00:4}`
+)
+
+// newBundle returns a zip archive with the files.
+func newBundle(t testing.TB, files [][2]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestBundleLoader(t *testing.T) {
+	files := [][2]string{
+		{"readme.md", "# Script"},
+		{"video.kiiroo", bundleKiiroo},
+		{"__MACOSX/._video.funscript", "garbage"},
+		{"video.roll.funscript", bundleRoll},
+		{"video.funscript", bundleFunscript},
+	}
+	cases := []struct {
+		Entry   string
+		Actions int
+		Err     error
+	}{
+		// Funscript set with the roll axis, the roll starts in the
+		// center so its first action does not move.
+		{"", 3, nil},
+		{"video.kiiroo", 2, nil},
+		{"video.roll.funscript", 3, nil},
+		{"video.mp4", 0, ErrEntryNotFound},
+		{"readme.md", 0, ErrUnsupported},
+	}
+	for _, c := range cases {
+		p, err := NewBundleLoader(c.Entry).Load(newBundle(t, files))
+		if err != c.Err {
+			t.Errorf("%q: want error %v, got %v", c.Entry, c.Err, err)
+			continue
+		} else if err != nil {
+			continue
+		}
+		d, err := p.(protocol.Dumpable).Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d) != c.Actions {
+			t.Errorf("%q: want %d actions, got %d", c.Entry,
+				c.Actions, len(d))
+		}
+	}
+
+	// Without a Funscript the kiiroo script is played.
+	p, err := LoadScript(newBundle(t, files[:2]), bundleMediaType,
+		NewPersonalization())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := p.(protocol.Dumpable).Dump(); len(d) != 2 {
+		t.Errorf("want kiiroo script, got %d actions", len(d))
+	}
+	if _, err := LoadScript(strings.NewReader("PK"), bundleMediaType,
+		NewPersonalization()); err != ErrUnsupported {
+		t.Errorf("invalid archive: want ErrUnsupported, got %v", err)
+	}
+}
+
+func TestBundleLimits(t *testing.T) {
+	// Highly compressible entry that expands beyond the limit.
+	big := strings.Repeat(" ", maxBundleEntrySize+1)
+	files := [][2]string{{"video.funscript", big}}
+	if _, err := NewBundleLoader("").Load(newBundle(t, files)); err != ErrBundleTooLarge {
+		t.Errorf("large entry: want ErrBundleTooLarge, got %v", err)
+	}
+
+	// Many entries that are each within the limit.
+	var many [][2]string
+	entry := strings.Repeat(" ", maxBundleEntrySize)
+	for i := 0; i <= maxBundleExtracted/maxBundleEntrySize; i++ {
+		many = append(many, [2]string{"video.funscript", entry})
+	}
+	if _, err := NewBundleLoader("").Load(newBundle(t, many)); err != ErrBundleTooLarge {
+		t.Errorf("large bundle: want ErrBundleTooLarge, got %v", err)
+	}
+}
+
+func TestBundleSharedLoaders(t *testing.T) {
+	files := [][2]string{{"video.funscript", bundleFunscript}}
+	before := fmt.Sprint(Loaders[0].Loader)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		pers := NewPersonalization()
+		pers.PositionMin = 10 + i
+		bundle := newBundle(t, files)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := LoadBundle(bundle, "", pers); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if after := fmt.Sprint(Loaders[0].Loader); after != before {
+		t.Errorf("registered loader changed from %q to %q", before, after)
+	}
+}
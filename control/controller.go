@@ -1,50 +1,71 @@
 package control
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/funjack/launchcontrol/device"
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+	"github.com/funjack/launchcontrol/protocol/kiiroo"
+	"github.com/funjack/launchcontrol/protocol/pattern"
 	"github.com/gorilla/websocket"
 )
 
+// loaderHeader is the response header with the name of the loader that
+// loaded the script.
+const loaderHeader = "X-Launchcontrol-Loader"
+
 // Controller translates http requests into manager actions.
 type Controller struct {
-	manager *device.LaunchManager
+	manager  *device.LaunchManager
+	profiles *ProfileStore
+	origins  Origins
+	limits   Limits
 }
 
-// NewController returns a new controller for the given manager.
+// NewController returns a new controller for the given manager. Profiles are
+// kept in memory until a ProfileStore is set.
 func NewController(m *device.LaunchManager) *Controller {
+	ps, _ := NewProfileStore("")
 	return &Controller{
-		manager: m,
+		manager:  m,
+		profiles: ps,
+		limits:   DefaultLimits,
 	}
 }
 
+// SetProfileStore switches the store used to lookup personalization profiles.
+func (c *Controller) SetProfileStore(s *ProfileStore) {
+	c.profiles = s
+}
+
+// SetLimits changes the limits of the scripts that are loaded.
+func (c *Controller) SetLimits(l Limits) {
+	c.limits = l
+}
+
+// SetAllowedOrigins sets the origins, besides the servers own, that browsers
+// may open websockets from.
+func (c *Controller) SetAllowedOrigins(o Origins) {
+	c.origins = o
+}
+
 // PlayHandler is a http.Handler to load and play scripts.
 func (c *Controller) PlayHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
-		pers := parsePlayParams(r.URL.Query())
-		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
-		if err != nil {
-			mediaType = ""
-		}
-		// Make form submitted data an unknown media type
-		if mediaType == "application/x-www-form-urlencoded" ||
-			mediaType == "multipart/form-data" {
-			mediaType = ""
-		}
-		k, err := LoadScript(r.Body, mediaType, pers)
-		if err == ErrUnsupported {
-			w.WriteHeader(http.StatusUnsupportedMediaType)
-			return
-		} else if err != nil {
-			log.Printf("Error loading script: %s\n", err)
-			internalServerError(w)
+		k, ok := c.loadRequest(w, r)
+		if !ok {
 			return
 		}
 		if err := c.manager.SetScriptPlayer(k); err != nil {
@@ -56,6 +77,178 @@ func (c *Controller) PlayHandler(w http.ResponseWriter, r *http.Request) {
 	handleManagerError(w, c.manager.Play())
 }
 
+// loadRequest loads the script in the request body using the personalization
+// profile and parameters from the query. It writes an error response and
+// returns false if the script could not be loaded.
+func (c *Controller) loadRequest(w http.ResponseWriter, r *http.Request) (protocol.Player, bool) {
+	q := r.URL.Query()
+	profile, err := c.profiles.Get(q.Get("profile"))
+	if err == ErrProfileNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("profile not found\n"))
+		return nil, false
+	}
+	pers := parsePlayParams(q, profile)
+	if !validAlgorithm(pers.Algorithm) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("unknown algorithm\n"))
+		return nil, false
+	}
+	limits := c.limits
+	deadline := limits.deadline()
+	data, err := limits.readBody(w, r, deadline)
+	if err == errConnClosed {
+		return nil, false
+	}
+	res := loadResult{err: err}
+	if err == nil {
+		contentType := r.Header.Get("Content-Type")
+		entry := q.Get("entry")
+		res = loadBefore(deadline, func() loadResult {
+			return loadBody(data, contentType, entry, pers)
+		})
+	}
+	if res.err == nil {
+		res.err = limits.check(res.player)
+	}
+	switch res.err {
+	case nil:
+		w.Header().Set(loaderHeader, res.report.Loader)
+	case ErrUnsupported:
+		writeUnsupported(w, res.report, res.err)
+		return nil, false
+	case errScriptSet:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid script set\n"))
+		return nil, false
+	case ErrEntryNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("entry not found in bundle\n"))
+		return nil, false
+	case ErrBundleTooLarge:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte("script bundle too large\n"))
+		return nil, false
+	case ErrScriptTooLarge:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintf(w, "script too large (max %d bytes)\n",
+			limits.MaxScriptSize)
+		return nil, false
+	case ErrTooManyActions:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintf(w, "script has too many actions (max %d)\n",
+			limits.MaxActions)
+		return nil, false
+	case ErrLoadTimeout:
+		w.WriteHeader(http.StatusRequestTimeout)
+		fmt.Fprintf(w, "loading script timed out (max %s)\n",
+			limits.LoadTimeout)
+		return nil, false
+	default:
+		if len(res.report.Errors) > 0 {
+			// Refused by the loader
+			writeUnsupported(w, res.report, res.err)
+			return nil, false
+		}
+		log.Printf("Error loading script: %s\n", res.err)
+		if err != nil {
+			// Error reading the request body
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("error reading script\n"))
+			return nil, false
+		}
+		internalServerError(w)
+		return nil, false
+	}
+	latencySeconds.Set(pers.Latency.Seconds())
+	return res.player, true
+}
+
+// loadBody loads the script in data with the content type of the request,
+// or the named entry of a script bundle.
+func loadBody(data []byte, contentType, entry string, pers Personalization) loadResult {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	var body io.Reader = bytes.NewReader(data)
+	if mediaType == "multipart/form-data" {
+		// Scripts uploaded as a set of files
+		if params["boundary"] == "" {
+			return loadResult{err: errScriptSet}
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		body, err = loadFunscriptSet(mr)
+		if err == ErrUnsupported {
+			return loadResult{err: err}
+		} else if err != nil {
+			return loadResult{err: errScriptSet}
+		}
+		mediaType = funscriptMediaType
+	}
+	// Make form submitted data an unknown media type
+	if mediaType == "application/x-www-form-urlencoded" {
+		mediaType = ""
+	}
+	if entry != "" {
+		// Named script in a bundle
+		var res loadResult
+		res.player, res.err = LoadBundle(body, entry, pers)
+		res.report.Loader = "zip"
+		if res.err != nil {
+			res.report.Errors = []LoaderError{{
+				Loader: "zip",
+				Error:  res.err.Error(),
+			}}
+		}
+		return res
+	}
+	var res loadResult
+	res.player, res.report, res.err = LoadScriptReport(body, mediaType, pers)
+	return res
+}
+
+// QueueHandler is a http.Handler to show (GET), add scripts to (POST) and
+// clear (DELETE) the queue. Scripts are added like they are played, with an
+// optional gap query parameter to wait before the script starts.
+func (c *Controller) QueueHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var gap time.Duration
+		if g := r.URL.Query().Get("gap"); g != "" {
+			var err error
+			if gap, err = time.ParseDuration(g); err != nil || gap < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("invalid gap\n"))
+				return
+			}
+		}
+		k, ok := c.loadRequest(w, r)
+		if !ok {
+			return
+		}
+		c.manager.Enqueue(k, gap)
+	case "DELETE":
+		c.manager.ClearQueue()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, c.manager.Queue())
+}
+
+// NextHandler is a http.Handler to switch to the next script in the queue.
+func (c *Controller) NextHandler(w http.ResponseWriter, r *http.Request) {
+	handleManagerError(w, c.manager.Next())
+}
+
+// PreviousHandler is a http.Handler to switch to the previous script in the
+// queue.
+func (c *Controller) PreviousHandler(w http.ResponseWriter, r *http.Request) {
+	handleManagerError(w, c.manager.Previous())
+}
+
 // StopHandler is a http.Handler to stop playback.
 func (c *Controller) StopHandler(w http.ResponseWriter, r *http.Request) {
 	handleManagerError(w, c.manager.Stop())
@@ -71,12 +264,31 @@ func (c *Controller) ResumeHandler(w http.ResponseWriter, r *http.Request) {
 	handleManagerError(w, c.manager.Resume())
 }
 
-// SkipHandler is a http.Handler to jump to a given timecode.
+// SkipHandler is a http.Handler to jump to a given timecode, or to the start
+// of a chapter or a bookmark by (zero based) index.
 func (c *Controller) SkipHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if v := r.Form.Get("chapter"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		handleManagerError(w, c.manager.SkipChapter(n))
+		return
+	}
+	if v := r.Form.Get("bookmark"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		handleManagerError(w, c.manager.SkipBookmark(n))
+		return
+	}
 	p, err := time.ParseDuration(r.Form.Get("p"))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -85,17 +297,220 @@ func (c *Controller) SkipHandler(w http.ResponseWriter, r *http.Request) {
 	handleManagerError(w, c.manager.Skip(p))
 }
 
-// DumpHandler is a http.Handler to dump the current script.
+// ChaptersHandler is a http.Handler that shows the chapters and bookmarks of
+// the loaded script.
+func (c *Controller) ChaptersHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.manager.Chapters())
+}
+
+// NextChapterHandler is a http.Handler to jump to the next chapter.
+func (c *Controller) NextChapterHandler(w http.ResponseWriter, r *http.Request) {
+	handleManagerError(w, c.manager.NextChapter())
+}
+
+// PreviousChapterHandler is a http.Handler to jump back to the start of the
+// playing chapter, or the chapter before it when it has just started.
+func (c *Controller) PreviousChapterHandler(w http.ResponseWriter, r *http.Request) {
+	handleManagerError(w, c.manager.PreviousChapter())
+}
+
+// HeartbeatHandler is a http.Handler that tells the watchdog the client is
+// still alive.
+func (c *Controller) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	c.manager.Heartbeat()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK\n"))
+}
+
+// StatusHandler is a http.Handler that shows the state of the manager.
+func (c *Controller) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.manager.Status())
+}
+
+// EmergencyStopHandler is a http.Handler to engage (POST), clear (DELETE) or
+// show (GET) the emergency stop. The emergency stop stays engaged, refusing
+// playback, until it is cleared.
+func (c *Controller) EmergencyStopHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		err := c.manager.EmergencyStop()
+		if err == device.ErrNotSupported {
+			handleManagerError(w, err)
+			return
+		} else if err != nil {
+			log.Printf("Error stopping playback: %s\n", err)
+		}
+	case "DELETE":
+		c.manager.ClearEmergencyStop()
+	case "GET":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, struct {
+		Engaged bool `json:"engaged"`
+	}{c.manager.EmergencyStopped()})
+}
+
+// KiirooStreamHandler is a http.Handler to play live Kiiroo events. Events
+// are read as they arrive from a chunked POST body or from the messages of a
+// websocket, playback stops when the stream ends. Personalization is taken
+// from the query like with PlayHandler.
+func (c *Controller) KiirooStreamHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	profile, err := c.profiles.Get(q.Get("profile"))
+	if err == ErrProfileNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("profile not found\n"))
+		return
+	}
+	pers := parsePlayParams(q, profile)
+	sp := kiiroo.NewStreamPlayer()
+	personalizePlayer(sp, pers)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		c.kiirooWebsocket(w, r, sp)
+		return
+	}
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := c.startStream(sp); err != nil {
+		handleManagerError(w, err)
+		return
+	}
+	defer sp.Stop()
+	err = kiiroo.PushStream(heartbeatReader{r.Body, c.manager}, sp)
+	if err == kiiroo.ErrEventFormat {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid event\n"))
+		return
+	} else if err != nil && err != kiiroo.ErrNotStreaming {
+		log.Printf("Error streaming events: %s\n", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK\n"))
+}
+
+// kiirooWebsocket plays the events received as websocket messages.
+func (c *Controller) kiirooWebsocket(w http.ResponseWriter, r *http.Request, sp *kiiroo.StreamPlayer) {
+	var upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     c.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	if err := c.startStream(sp); err != nil {
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+				err.Error()))
+		return
+	}
+	defer sp.Stop()
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.manager.Heartbeat()
+		err = kiiroo.PushStream(bytes.NewReader(msg), sp)
+		if err == kiiroo.ErrEventFormat {
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(
+					websocket.CloseInvalidFramePayloadData,
+					err.Error()))
+			return
+		} else if err != nil {
+			return
+		}
+	}
+}
+
+// startStream loads and plays a stream player.
+func (c *Controller) startStream(sp *kiiroo.StreamPlayer) error {
+	if err := c.manager.SetScriptPlayer(sp); err != nil {
+		return err
+	}
+	return c.manager.Play()
+}
+
+// heartbeatReader sends a heartbeat to the manager on every read.
+type heartbeatReader struct {
+	io.Reader
+	manager *device.LaunchManager
+}
+
+// Read implements io.Reader.
+func (h heartbeatReader) Read(p []byte) (int, error) {
+	n, err := h.Reader.Read(p)
+	if n > 0 {
+		h.manager.Heartbeat()
+	}
+	return n, err
+}
+
+// TuneHandler is a http.Handler to change the tempo and depth of a playing
+// pattern.
+func (c *Controller) TuneHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var tempo, depth int
+	var err error
+	if v := r.Form.Get("tempo"); v != "" {
+		if tempo, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.Form.Get("depth"); v != "" {
+		if depth, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	err = c.manager.Tune(tempo, depth)
+	if err == pattern.ErrOutOfRange {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("tempo or depth out of range\n"))
+		return
+	}
+	handleManagerError(w, err)
+}
+
+// DumpHandler is a http.Handler to dump the current script. The script is
+// dumped as timed actions, or exported as a (multi-axis) Funscript including
+// the metadata with the format=funscript query parameter.
 func (c *Controller) DumpHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "funscript" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("unknown format\n"))
+		return
+	}
 	script, err := c.manager.Dump()
 	if err != nil {
 		handleManagerError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	var v interface{} = &script
+	if format == "funscript" {
+		w.Header().Set("Content-Type", "application/prs.funscript+json")
+		fs := funscript.FromTimedActions(script)
+		fs.Metadata = c.manager.Metadata()
+		v = fs
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	e := json.NewEncoder(w)
-	err = e.Encode(&script)
+	err = e.Encode(v)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("internal server error"))
@@ -103,12 +518,87 @@ func (c *Controller) DumpHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// ProfilesHandler is a http.Handler to list, read, create, update and delete
+// personalization profiles. It serves both the collection (/v1/profiles) and
+// the individual profiles (/v1/profiles/<name>). PUT and POST replace a
+// profile, missing values get their default. PATCH only changes the values
+// given.
+func (c *Controller) ProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/profiles"), "/")
+	if name == "" {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, c.profiles.Profiles())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		p, err := c.profiles.Get(name)
+		if err != nil {
+			handleProfileError(w, err)
+			return
+		}
+		writeJSON(w, p)
+	case "PUT", "POST", "PATCH":
+		p := NewPersonalization()
+		if r.Method == "PATCH" {
+			var err error
+			if p, err = c.profiles.Get(name); err != nil {
+				handleProfileError(w, err)
+				return
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid profile\n"))
+			return
+		}
+		if err := c.profiles.Set(name, p); err != nil {
+			handleProfileError(w, err)
+			return
+		}
+		writeJSON(w, p)
+	case "DELETE":
+		handleProfileError(w, c.profiles.Delete(name))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// heartbeatMessage is the websocket message a client sends as heartbeat.
+const heartbeatMessage = "heartbeat"
+
 // WebsocketHandler implements http.Handler that reponds with a websocket
-// writing status messages in JSON.
+// writing status messages and safety violations in JSON. Other device events
+// are only written when requested with the events=1 query parameter. A
+// "heartbeat" message from the client is a heartbeat for the watchdog. With
+// the live=1 query parameter JSON encoded messages received from the client
+// are played as live positions, using the personalization from the query.
 func (c *Controller) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var session *liveSession
+	if q.Get("live") == "1" {
+		profile, err := c.profiles.Get(q.Get("profile"))
+		if err == ErrProfileNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("profile not found\n"))
+			return
+		}
+		pers := parsePlayParams(q, profile)
+		session = &liveSession{
+			manager: c.manager,
+			personalization: func() Personalization {
+				return pers
+			},
+		}
+	}
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		CheckOrigin:     c.checkOrigin,
 	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -116,20 +606,59 @@ func (c *Controller) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	go func() {
+		if session != nil {
+			defer session.close()
+		}
 		for {
-			if _, _, err := conn.NextReader(); err != nil {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
 				conn.Close()
 				break
 			}
+			if session != nil && isLiveMessage(msg) {
+				// Errors are ignored, the websocket is only
+				// written to by the handler.
+				session.push(msg)
+				continue
+			}
+			if string(msg) == heartbeatMessage {
+				c.manager.Heartbeat()
+			}
 		}
 	}()
-	for a := range c.manager.Trace() {
-		if err = conn.WriteJSON(a); err != nil {
+	trace := c.manager.Trace()
+	defer c.manager.CloseTrace(trace)
+	var events <-chan device.Event
+	if q.Get("events") == "1" {
+		events = c.manager.Events()
+		defer c.manager.CloseEvents(events)
+	}
+	for {
+		var msg interface{}
+		select {
+		case v, ok := <-trace:
+			if !ok {
+				return
+			}
+			msg = v
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			msg = e
+		}
+		if err = conn.WriteJSON(msg); err != nil {
 			return
 		}
 	}
 }
 
+// checkOrigin returns true if the websocket request comes from the same
+// origin as the server or from an allowed origin.
+func (c *Controller) checkOrigin(r *http.Request) bool {
+	return c.origins.allowedRequest(r)
+}
+
 // handleManagerError writes a http response based on a manager error.
 func handleManagerError(w http.ResponseWriter, err error) {
 	switch err {
@@ -142,12 +671,70 @@ func handleManagerError(w http.ResponseWriter, err error) {
 	case device.ErrNotPlaying:
 		w.WriteHeader(http.StatusConflict)
 		w.Write([]byte("operation cannot be executed when not playing\n"))
+	case device.ErrQueueEnd:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("no more scripts in queue\n"))
+	case device.ErrChapterNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("chapter not found\n"))
+	case device.ErrEmergencyStop:
+		w.WriteHeader(http.StatusLocked)
+		w.Write([]byte("emergency stop engaged\n"))
 	default:
 		log.Printf("Internal server error, %s\n", err)
 		internalServerError(w)
 	}
 }
 
+// handleProfileError writes a http response based on a profile store error.
+func handleProfileError(w http.ResponseWriter, err error) {
+	if perr, ok := err.(*PersonalizationError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(perr.Error() + "\n"))
+		return
+	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK\n"))
+	case ErrProfileNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("profile not found\n"))
+	case ErrProfileName:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid profile name\n"))
+	default:
+		log.Printf("Error storing profile: %s\n", err)
+		internalServerError(w)
+	}
+}
+
+// writeJSON writes v as JSON encoded response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	if err := e.Encode(v); err != nil {
+		log.Printf("Error encoding response: %s\n", err)
+	}
+}
+
+// writeUnsupported returns a status 415 with err and the reasons the loaders
+// could not load the script.
+func writeUnsupported(w http.ResponseWriter, report LoadReport, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	v := struct {
+		Error   string        `json:"error"`
+		Loaders []LoaderError `json:"loaders"`
+	}{
+		Error:   err.Error(),
+		Loaders: report.Errors,
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %s\n", err)
+	}
+}
+
 // internalServerError returns a status 500 with message to a ResponseWriter.
 func internalServerError(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusInternalServerError)
@@ -155,8 +742,8 @@ func internalServerError(w http.ResponseWriter) {
 }
 
 // parsePlayParams extracts personalization values from the query params.
-func parsePlayParams(q url.Values) Personalization {
-	p := NewPersonalization()
+// Values not present in the query are taken from p.
+func parsePlayParams(q url.Values, p Personalization) Personalization {
 	if i, err := strconv.Atoi(q.Get("latency")); err == nil {
 		p.Latency = time.Duration(i) * time.Millisecond
 	}
@@ -172,5 +759,11 @@ func parsePlayParams(q url.Values) Personalization {
 	if i, err := strconv.Atoi(q.Get("speedmax")); err == nil {
 		p.SpeedMax = i
 	}
+	if a := q.Get("algorithm"); a != "" {
+		p.Algorithm = a
+	}
+	if b, err := strconv.ParseBool(q.Get("invert")); err == nil {
+		p.Invert = b
+	}
 	return p
 }
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package control
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func FuzzLoadScript(f *testing.F) {
+	// Content types of all loaders, and none.
+	contentTypes := []string{""}
+	for _, l := range Loaders {
+		contentTypes = append(contentTypes, l.ContentTypes...)
+	}
+	seeds := []string{
+		bundleFunscript,
+		bundleKiiroo,
+		`[{"at":100,"pos":50,"spd":50}]`,
+		"[Kiiroo]\nonyx=1.0,1;2.5,4\n",
+		`{"subs":{"text":"{1.00:1}"}}`,
+		`{"waveform":"sine","tempo":60}`,
+		newBundle(f, [][2]string{
+			{"video.funscript", bundleFunscript},
+			{"video.roll.funscript", bundleRoll},
+		}).String(),
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s), uint8(0))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, ct uint8) {
+		contentType := contentTypes[int(ct)%len(contentTypes)]
+		p, err := LoadScript(bytes.NewReader(data), contentType,
+			NewPersonalization())
+		if err != nil {
+			return
+		}
+		d, ok := p.(protocol.Dumpable)
+		if !ok {
+			return
+		}
+		actions, err := d.Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := actions.Validate(); err != nil {
+			t.Fatalf("%s: invalid actions: %v", contentType, err)
+		}
+	})
+}
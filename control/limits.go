@@ -0,0 +1,185 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+var (
+	// ErrScriptTooLarge is returned when a script exceeds the maximum
+	// size.
+	ErrScriptTooLarge = errors.New("script too large")
+	// ErrTooManyActions is returned when a loaded script has more actions
+	// than allowed.
+	ErrTooManyActions = errors.New("script has too many actions")
+	// ErrLoadTimeout is returned when a script could not be received and
+	// loaded in time.
+	ErrLoadTimeout = errors.New("loading script timed out")
+
+	// errConnClosed is returned when the response was written and the
+	// connection closed while reading the request.
+	errConnClosed = errors.New("connection closed")
+)
+
+// Limits restrict the scripts that are loaded from requests. Zero values
+// disable a limit.
+type Limits struct {
+	// MaxScriptSize is the maximum size of a script in bytes.
+	MaxScriptSize int
+	// MaxActions is the maximum number of actions of a loaded script.
+	MaxActions int
+	// LoadTimeout is the time in which a script must be received and
+	// loaded.
+	LoadTimeout time.Duration
+}
+
+// DefaultLimits are the limits used by a new Controller.
+var DefaultLimits = Limits{
+	MaxScriptSize: maxBundleSize,
+	MaxActions:    1000000,
+	LoadTimeout:   time.Second * 30,
+}
+
+// deadline returns the time loading a script started now must be done, or
+// the zero time when there is no timeout.
+func (l Limits) deadline() time.Time {
+	if l.LoadTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(l.LoadTimeout)
+}
+
+// reader returns a reader that fails with ErrScriptTooLarge when r has more
+// data than the maximum size, or with ErrLoadTimeout when reading continues
+// past deadline.
+func (l Limits) reader(r io.Reader, deadline time.Time) io.Reader {
+	return &limitedReader{
+		r:        r,
+		max:      l.MaxScriptSize,
+		deadline: deadline,
+	}
+}
+
+// readBody reads the body of r. When the client stops sending before deadline
+// the connection is taken over from the server to abort the pending read, a
+// timeout response is written, the connection is closed and errConnClosed is
+// returned.
+func (l Limits) readBody(w http.ResponseWriter, r *http.Request, deadline time.Time) ([]byte, error) {
+	if deadline.IsZero() {
+		return ioutil.ReadAll(l.reader(r.Body, deadline))
+	}
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := ioutil.ReadAll(l.reader(r.Body, deadline))
+		done <- result{data, err}
+	}()
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-t.C:
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		// HTTP/2 aborts the read when the handler returns.
+		return nil, ErrLoadTimeout
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, ErrLoadTimeout
+	}
+	msg := fmt.Sprintf("loading script timed out (max %s)\n", l.LoadTimeout)
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	fmt.Fprintf(buf, "HTTP/1.1 408 Request Timeout\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"Content-Length: %d\r\nConnection: close\r\n\r\n%s", len(msg), msg)
+	buf.Flush()
+	conn.Close()
+	<-done
+	return nil, errConnClosed
+}
+
+// check returns ErrTooManyActions when the script of p has more actions than
+// allowed.
+func (l Limits) check(p protocol.Player) error {
+	d, ok := p.(protocol.Dumpable)
+	if l.MaxActions <= 0 || !ok {
+		return nil
+	}
+	actions, err := d.Dump()
+	if err != nil {
+		return err
+	}
+	if len(actions) > l.MaxActions {
+		return ErrTooManyActions
+	}
+	return nil
+}
+
+// limitedReader reads from r until more than max bytes are read or the
+// deadline has passed.
+type limitedReader struct {
+	r        io.Reader
+	max      int       // no limit when 0
+	read     int       // bytes read so far
+	deadline time.Time // no deadline when zero
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return 0, ErrLoadTimeout
+	}
+	if l.max <= 0 {
+		return l.r.Read(p)
+	}
+	// Read one byte more than allowed to detect scripts that are too
+	// large.
+	if left := l.max - l.read + 1; len(p) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	l.read += n
+	if l.read > l.max {
+		return n, ErrScriptTooLarge
+	}
+	return n, err
+}
+
+// loadResult is the outcome of loading a script.
+type loadResult struct {
+	player protocol.Player
+	report LoadReport
+	err    error
+}
+
+// loadBefore runs load and returns its result, or ErrLoadTimeout when it is
+// not done before deadline. Load keeps running in the background after a
+// timeout, so it must not use anything of the request or change shared state.
+func loadBefore(deadline time.Time, load func() loadResult) loadResult {
+	if deadline.IsZero() {
+		return load()
+	}
+	done := make(chan loadResult, 1)
+	go func() {
+		done <- load()
+	}()
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case res := <-done:
+		return res
+	case <-t.C:
+		return loadResult{err: ErrLoadTimeout}
+	}
+}
@@ -0,0 +1,103 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitedReader(t *testing.T) {
+	l := Limits{MaxScriptSize: 4}
+	if _, err := ioutil.ReadAll(l.reader(strings.NewReader("1234"), time.Time{})); err != nil {
+		t.Errorf("script at maximum size: %v", err)
+	}
+	if _, err := ioutil.ReadAll(l.reader(strings.NewReader("12345"), time.Time{})); err != ErrScriptTooLarge {
+		t.Errorf("want ErrScriptTooLarge, got %v", err)
+	}
+	l.MaxScriptSize = 0
+	past := time.Now().Add(-time.Second)
+	if _, err := ioutil.ReadAll(l.reader(strings.NewReader("12345"), past)); err != ErrLoadTimeout {
+		t.Errorf("want ErrLoadTimeout, got %v", err)
+	}
+}
+
+func TestLoadBefore(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	res := loadBefore(time.Now().Add(time.Millisecond*10), func() loadResult {
+		<-block
+		return loadResult{}
+	})
+	if res.err != ErrLoadTimeout {
+		t.Errorf("want ErrLoadTimeout, got %v", res.err)
+	}
+	res = loadBefore(time.Time{}, func() loadResult {
+		return loadResult{err: ErrUnsupported}
+	})
+	if res.err != ErrUnsupported {
+		t.Errorf("want result of load, got %v", res.err)
+	}
+}
+
+func TestLoadRequestLimits(t *testing.T) {
+	cases := []struct {
+		Limits      Limits
+		ContentType string
+		Status      int
+	}{
+		{DefaultLimits, funscriptMediaType, http.StatusOK},
+		{Limits{MaxScriptSize: 10}, funscriptMediaType, http.StatusRequestEntityTooLarge},
+		{Limits{MaxActions: 1}, funscriptMediaType, http.StatusUnprocessableEntity},
+		{Limits{LoadTimeout: time.Nanosecond}, funscriptMediaType, http.StatusRequestTimeout},
+		{DefaultLimits, "multipart/form-data", http.StatusBadRequest},
+	}
+	for i, tc := range cases {
+		c := NewController(nil)
+		c.SetLimits(tc.Limits)
+		r := httptest.NewRequest("POST", "/v1/play",
+			bytes.NewBufferString(bundleFunscript))
+		r.Header.Set("Content-Type", tc.ContentType)
+		w := httptest.NewRecorder()
+		_, ok := c.loadRequest(w, r)
+		if ok != (tc.Status == http.StatusOK) || w.Code != tc.Status {
+			t.Errorf("case %d: want status %d, got %d (%t): %s", i,
+				tc.Status, w.Code, ok, w.Body)
+		}
+	}
+}
+
+func TestLoadRequestStalledBody(t *testing.T) {
+	c := NewController(nil)
+	c.SetLimits(Limits{LoadTimeout: time.Millisecond * 100})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.loadRequest(w, r)
+	}))
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Send only the start of the body and wait
+	fmt.Fprintf(conn, "POST /v1/play HTTP/1.1\r\nHost: test\r\n"+
+		"Content-Type: %s\r\nContent-Length: 1000\r\n\r\n{",
+		funscriptMediaType)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("want status %d, got %d", http.StatusRequestTimeout,
+			resp.StatusCode)
+	}
+}
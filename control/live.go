@@ -0,0 +1,94 @@
+package control
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/funjack/launchcontrol/device"
+	"github.com/funjack/launchcontrol/protocol/live"
+)
+
+// maxDatagramSize is the largest UDP message with live positions read.
+const maxDatagramSize = 64 * 1024
+
+// errScriptPlaying is returned when live positions are received while a
+// script is playing.
+var errScriptPlaying = errors.New("script is playing")
+
+// liveSession pushes live positions to a live player. A new player is loaded
+// and started when the session has none, or when it was stopped and nothing
+// else is playing. Positions received while a script plays are dropped.
+type liveSession struct {
+	manager         *device.LaunchManager
+	personalization func() Personalization
+	player          *live.Player
+}
+
+// push the JSON encoded messages in msg.
+func (s *liveSession) push(msg []byte) error {
+	s.manager.Heartbeat()
+	if s.player != nil {
+		err := live.PushJSON(bytes.NewReader(msg), s.player)
+		if err != live.ErrNotStreaming {
+			return err
+		}
+	}
+	if err := s.start(); err != nil {
+		return err
+	}
+	return live.PushJSON(bytes.NewReader(msg), s.player)
+}
+
+// start loads and plays a new live player, unless a script is playing.
+func (s *liveSession) start() error {
+	if st := s.manager.Status(); st.Playing || st.Paused {
+		return errScriptPlaying
+	}
+	p := live.NewPlayer()
+	personalizePlayer(p, s.personalization())
+	if err := s.manager.SetScriptPlayer(p); err != nil {
+		return err
+	}
+	if err := s.manager.Play(); err != nil {
+		return err
+	}
+	s.player = p
+	return nil
+}
+
+// close stops the live player.
+func (s *liveSession) close() {
+	if s.player != nil {
+		s.player.Stop()
+	}
+}
+
+// isLiveMessage returns true when msg looks like a JSON encoded live message.
+func isLiveMessage(msg []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(msg), []byte("{"))
+}
+
+// ServeLiveUDP plays the live positions received on conn using the default
+// profile. Every datagram contains one or more JSON encoded messages.
+func (c *Controller) ServeLiveUDP(conn net.PacketConn) error {
+	s := &liveSession{
+		manager: c.manager,
+		personalization: func() Personalization {
+			p, _ := c.profiles.Get(DefaultProfile)
+			return p
+		},
+	}
+	defer s.close()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if err := s.push(buf[:n]); err == live.ErrInvalidMessage {
+			log.Printf("Invalid live message from %s\n", addr)
+		}
+	}
+}
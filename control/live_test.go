@@ -0,0 +1,56 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/device"
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// fakeDevice is a linear device that ignores all moves.
+type fakeDevice struct{}
+
+func (fakeDevice) Connect(ctx context.Context) error { return nil }
+func (fakeDevice) Disconnect()                       {}
+func (fakeDevice) HandleDisconnect(func())           {}
+func (fakeDevice) Capabilities() device.Capability   { return device.Linear }
+func (fakeDevice) Move(position, speed int)          {}
+
+func TestLiveSessionScriptPlaying(t *testing.T) {
+	lm := device.NewLaunchManager(fakeDevice{})
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = []protocol.TimedAction{
+		{Action: protocol.Action{Position: 10, Speed: 50}, Time: time.Minute},
+	}
+	if err := lm.SetScriptPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	s := &liveSession{
+		manager: lm,
+		personalization: func() Personalization {
+			return DefaultPersonalization
+		},
+	}
+	defer s.close()
+	msg := []byte(`{"pos":50,"spd":50}`)
+	if err := s.push(msg); err != errScriptPlaying {
+		t.Errorf("push while playing, want %v, got %v", errScriptPlaying, err)
+	}
+	if s.player != nil {
+		t.Errorf("live player started while a script is playing")
+	}
+	if err := lm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.push(msg); err != nil {
+		t.Errorf("push after stop: %v", err)
+	}
+	if s.player == nil {
+		t.Errorf("live player not started after stop")
+	}
+}
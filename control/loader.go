@@ -2,36 +2,48 @@ package control
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"strings"
 	"time"
 
 	"github.com/funjack/launchcontrol/protocol"
 	"github.com/funjack/launchcontrol/protocol/funscript"
 	"github.com/funjack/launchcontrol/protocol/kiiroo"
+	"github.com/funjack/launchcontrol/protocol/pattern"
 	"github.com/funjack/launchcontrol/protocol/raw"
 )
 
+// funscriptMediaType is the media type of Funscripts.
+const funscriptMediaType = "application/prs.funscript+json"
+
 // Loaders contains all the registered ScriptLoaders.
 var Loaders = []Loader{
 	{
+		Name:   "funscript",
 		Loader: &funscript.Loader{},
+		Sniff:  sniffFunscript,
 		ContentTypes: []string{
-			"application/prs.funscript+json",
+			funscriptMediaType,
 			"application/json",
 		},
 	},
 	{
+		Name:   "raw",
 		Loader: protocol.LoaderFunc(raw.Load),
+		Sniff:  sniffRaw,
 		ContentTypes: []string{
 			"application/prs.launchcontrol+json",
 			"application/json",
 		},
 	},
 	{
+		Name:   "kiiroo",
 		Loader: protocol.LoaderFunc(kiiroo.Load),
+		Sniff:  sniffKiiroo,
 		ContentTypes: []string{
 			"text/prs.kiiroo",
 			"x-text/kiiroo",
@@ -39,48 +51,114 @@ var Loaders = []Loader{
 		},
 	},
 	{
+		Name:   "kiiroo-text",
 		Loader: protocol.LoaderFunc(kiiroo.LoadText),
+		Sniff:  sniffKiirooText,
 		ContentTypes: []string{
 			"text/plain",
 		},
 	},
 	{
+		Name:   "kiiroo-json",
 		Loader: protocol.LoaderFunc(kiiroo.LoadJSON),
+		Sniff:  sniffKiirooJSON,
 		ContentTypes: []string{
 			"application/prs.kiiroo+json",
 			"application/json",
 		},
 	},
+	{
+		Name:   "zip",
+		Loader: NewBundleLoader(""),
+		Sniff:  sniffBundle,
+		ContentTypes: []string{
+			bundleMediaType,
+			"application/x-zip-compressed",
+		},
+	},
+	{
+		Name:   "pattern",
+		Loader: protocol.LoaderFunc(pattern.Load),
+		Sniff:  sniffPattern,
+		ContentTypes: []string{
+			"application/prs.launchcontrol-pattern+json",
+		},
+	},
 }
 
 // ErrUnsupported is returned when the script can't be loaded by any
 // scriptplayer.
 var ErrUnsupported = errors.New("unsupported script")
 
+// errNoMatch is reported for loaders that are skipped because the content is
+// not in their format.
+var errNoMatch = errors.New("content is not in this format")
+
+// errScriptSet is returned when a multipart form with a set of scripts can not
+// be read.
+var errScriptSet = errors.New("invalid script set")
+
+// ErrUnknownAlgorithm is returned when a Personalization uses a Kiiroo
+// algorithm that is not registered.
+var ErrUnknownAlgorithm = errors.New("unknown kiiroo algorithm")
+
 // Personalization are settings customizing a scripts behaviour
 type Personalization struct {
 	Latency     time.Duration
-	PositionMin int // Lowest position
-	PositionMax int // Highest position
-	SpeedMin    int // Slowest speed to move at
-	SpeedMax    int // Fastest speed to move at
+	PositionMin int    // Lowest position
+	PositionMax int    // Highest position
+	SpeedMin    int    // Slowest speed to move at
+	SpeedMax    int    // Fastest speed to move at
+	Algorithm   string // Kiiroo algorithm, the default when empty
+	Invert      bool   // Move up when the script moves down
+}
+
+// validAlgorithm returns true if name is empty or a registered Kiiroo
+// algorithm.
+func validAlgorithm(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := kiiroo.Algorithms[name]
+	return ok
+}
+
+// DefaultPersonalization contains the values returned by NewPersonalization.
+var DefaultPersonalization = Personalization{
+	Latency:     0,
+	PositionMin: 5,
+	PositionMax: 95,
+	SpeedMin:    20,
+	SpeedMax:    80,
 }
 
 // NewPersonalization return a Personalization with the default values.
 func NewPersonalization() Personalization {
-	return Personalization{
-		Latency:     0,
-		PositionMin: 5,
-		PositionMax: 95,
-		SpeedMin:    20,
-		SpeedMax:    80,
-	}
+	return DefaultPersonalization
 }
 
 // Loader wraps a scriptloader with it's supported mediatypes.
 type Loader struct {
+	Name         string // Name of the script format
 	Loader       protocol.Loader
 	ContentTypes []string
+	// Sniff scores how likely the content is in the format of the loader,
+	// negative when it can not be. Loaders are tried from the highest to
+	// the lowest score. (optional)
+	Sniff func(data []byte) int
+}
+
+// LoadReport describes which loaders were tried to load a script.
+type LoadReport struct {
+	Loader string        `json:"loader,omitempty"` // Loader that loaded the script
+	Errors []LoaderError `json:"loaders"`          // Loaders that failed
+}
+
+// LoaderError is the reason a loader could not load a script.
+type LoaderError struct {
+	Loader string `json:"loader"`
+	Score  int    `json:"score"`
+	Error  string `json:"error"`
 }
 
 // IsSupported checks if the loader can handle specified content type.
@@ -97,54 +175,153 @@ func (l Loader) IsSupported(contentType string) bool {
 // the first one that's succesfull.
 // Loaders that are tried can be filtered by specifying the content type.
 func LoadScript(r io.Reader, contentType string, p Personalization) (protocol.Player, error) {
-	supportedLoaders := make([]protocol.Loader, 0, len(Loaders))
+	sp, _, err := LoadScriptReport(r, contentType, p)
+	return sp, err
+}
+
+// LoadScriptReport is like LoadScript but also reports which loader loaded
+// the script, and why the other loaders failed. The loaders are tried in the
+// order of how likely the content is in their format, loaders that can not
+// load the content are skipped. When only one loader supports the content
+// type its error is returned, otherwise ErrUnsupported.
+func LoadScriptReport(r io.Reader, contentType string, p Personalization) (protocol.Player, LoadReport, error) {
+	report := LoadReport{Errors: []LoaderError{}}
+	supportedLoaders := make([]Loader, 0, len(Loaders))
 	for _, s := range Loaders {
 		if contentType == "" || s.IsSupported(contentType) {
-			supportedLoaders = append(supportedLoaders, s.Loader)
+			supportedLoaders = append(supportedLoaders, s)
 		}
 	}
 	// Just pass the reader if there is only one supported loader.
 	if len(supportedLoaders) == 1 {
-		return load(supportedLoaders[0], r, p)
+		l := supportedLoaders[0]
+		sp, err := load(l, r, p)
+		if err != nil {
+			scriptLoadFailuresTotal.Inc()
+			report.Errors = append(report.Errors, LoaderError{
+				Loader: l.Name,
+				Error:  err.Error(),
+			})
+			return nil, report, err
+		}
+		report.Loader = l.Name
+		return sp, report, nil
 	}
 	// Make a copy of the readers contents to be used multiple times.
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, report, err
+	}
+	for _, c := range sniff(supportedLoaders, data) {
+		e := LoaderError{
+			Loader: c.loader.Name,
+			Score:  c.score,
+		}
+		if c.score < 0 {
+			e.Error = errNoMatch.Error()
+			report.Errors = append(report.Errors, e)
+			continue
+		}
+		sp, err := load(c.loader, bytes.NewBuffer(data), p)
+		if err == nil {
+			report.Loader = c.loader.Name
+			return sp, report, nil
+		}
+		e.Error = err.Error()
+		report.Errors = append(report.Errors, e)
 	}
-	for _, loader := range supportedLoaders {
-		if sp, err := load(loader, bytes.NewBuffer(data), p); err == nil {
-			return sp, nil
+	scriptLoadFailuresTotal.Inc()
+	return nil, report, ErrUnsupported
+}
+
+// loadFunscriptSet combines the Funscripts in a multipart form into a single
+// multi-axis Funscript. The axis of each script is derived from its filename
+// (eg video.roll.funscript), parts that are not Funscripts are ignored.
+func loadFunscriptSet(mr *multipart.Reader) (io.Reader, error) {
+	var set funscriptSet
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if err := set.add(part.FileName(), part); err != nil {
+			return nil, err
 		}
 	}
-	return nil, ErrUnsupported
+	return set.reader()
+}
+
+// funscriptSet combines Funscripts of different axes into one script.
+type funscriptSet struct {
+	script funscript.Script
+	found  bool
+}
+
+// add adds the script in r to the set, using the filename to determine the
+// axis. Files that are not Funscripts are ignored.
+func (s *funscriptSet) add(name string, r io.Reader) error {
+	axis, ok := funscript.AxisFromFilename(name)
+	if !ok {
+		return nil
+	}
+	var o funscript.Script
+	if err := json.NewDecoder(r).Decode(&o); err != nil {
+		return err
+	}
+	s.script.AddAxis(axis, o)
+	s.found = true
+	return nil
+}
+
+// reader returns the combined script as JSON.
+func (s *funscriptSet) reader() (io.Reader, error) {
+	if !s.found {
+		return nil, ErrUnsupported
+	}
+	data, err := json.Marshal(s.script)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
 }
 
 // load will try to load the content of r with scriptloader l and return it's
 // player.
-func load(l protocol.Loader, r io.Reader, pers Personalization) (protocol.Player, error) {
-	personalizeLoader(l, pers)
-	p, err := l.Load(r)
+func load(l Loader, r io.Reader, pers Personalization) (protocol.Player, error) {
+	p, err := personalizedLoader(l.Loader, pers).Load(r)
 	if err != nil {
 		return nil, err
 	}
 	personalizePlayer(p, pers)
+	scriptsLoadedTotal.With(l.Name).Inc()
 	return p, nil
 }
 
-// personalizeLoader will apply, if supported, personalized position and speed
-// limits to the loader.
-func personalizeLoader(l protocol.Loader, pers Personalization) {
+// personalizedLoader returns a loader with, if supported, personalized
+// position and speed limits. Loaders with settings are copied, the registered
+// Loaders are shared by concurrent requests and are not changed.
+func personalizedLoader(l protocol.Loader, pers Personalization) protocol.Loader {
+	switch v := l.(type) {
+	case *funscript.Loader:
+		c := *v
+		l = &c
+	case *BundleLoader:
+		c := *v
+		l = &c
+	}
 	if pl, ok := l.(protocol.PositionLimiter); ok {
 		pl.LimitPosition(pers.PositionMin, pers.PositionMax)
 	}
 	if sl, ok := l.(protocol.SpeedLimiter); ok {
 		sl.LimitSpeed(pers.SpeedMin, pers.SpeedMax)
 	}
+	return l
 }
 
 // personalizePlayer will apply, if supported, personalized latency, position
-// and speed limits to the loader.
+// and speed limits, inversion and the Kiiroo algorithm to the player.
 func personalizePlayer(p protocol.Player, pers Personalization) {
 	if lc, ok := p.(protocol.LatencyCalibrator); ok {
 		lc.Latency(pers.Latency)
@@ -155,4 +332,12 @@ func personalizePlayer(p protocol.Player, pers Personalization) {
 	if sl, ok := p.(protocol.SpeedLimiter); ok {
 		sl.LimitSpeed(pers.SpeedMin, pers.SpeedMax)
 	}
+	if i, ok := p.(protocol.Inverter); ok {
+		i.Invert(pers.Invert)
+	}
+	if kp, ok := p.(*kiiroo.ScriptPlayer); ok && pers.Algorithm != "" {
+		if a, ok := kiiroo.Algorithms[pers.Algorithm]; ok {
+			kp.SetAlgorithm(a)
+		}
+	}
 }
@@ -0,0 +1,30 @@
+package control
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadScriptSharedLoaders(t *testing.T) {
+	before := fmt.Sprint(Loaders[0].Loader)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		pers := NewPersonalization()
+		pers.PositionMin = 10 + i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := LoadScript(strings.NewReader(bundleFunscript),
+				funscriptMediaType, pers)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if after := fmt.Sprint(Loaders[0].Loader); after != before {
+		t.Errorf("registered loader changed from %q to %q", before, after)
+	}
+}
@@ -0,0 +1,12 @@
+package control
+
+import "github.com/funjack/launchcontrol/metrics"
+
+var (
+	scriptsLoadedTotal = metrics.NewCounterVec("launchcontrol_scripts_loaded_total",
+		"Number of scripts loaded by format.", "format")
+	scriptLoadFailuresTotal = metrics.NewCounter("launchcontrol_script_load_failures_total",
+		"Number of scripts that could not be loaded.")
+	latencySeconds = metrics.NewGauge("launchcontrol_latency_seconds",
+		"Latency setting of the playing script.")
+)
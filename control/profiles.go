@@ -0,0 +1,238 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProfile is the name of the profile used when a play request does not
+// specify one.
+const DefaultProfile = "default"
+
+var (
+	// ErrProfileNotFound is returned when the requested profile does not
+	// exist.
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileName is returned when a profile name can not be used.
+	ErrProfileName = errors.New("invalid profile name")
+)
+
+// PersonalizationError is returned when a Personalization has a value out of
+// range.
+type PersonalizationError struct {
+	Field  string // JSON name of the field
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *PersonalizationError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// Validate returns a *PersonalizationError when p has values out of range.
+func (p Personalization) Validate() error {
+	switch {
+	case p.Latency < 0:
+		return &PersonalizationError{"latency", "must be positive"}
+	case p.PositionMin < 0 || p.PositionMax > 100 ||
+		p.PositionMin >= p.PositionMax:
+		return &PersonalizationError{"positionmin/positionmax",
+			"must be 0-100 with min below max"}
+	case p.SpeedMin < 0 || p.SpeedMax > 100 || p.SpeedMin >= p.SpeedMax:
+		return &PersonalizationError{"speedmin/speedmax",
+			"must be 0-100 with min below max"}
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (p Personalization) MarshalJSON() ([]byte, error) {
+	c := personalizationJSON{
+		Latency:     p.Latency.Nanoseconds() / 1e6,
+		PositionMin: p.PositionMin,
+		PositionMax: p.PositionMax,
+		SpeedMin:    p.SpeedMin,
+		SpeedMax:    p.SpeedMax,
+		Algorithm:   p.Algorithm,
+		Invert:      p.Invert,
+	}
+	return json.Marshal(&c)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Fields missing
+// from the input keep their current value.
+func (p *Personalization) UnmarshalJSON(in []byte) error {
+	c := personalizationJSON{
+		Latency:     p.Latency.Nanoseconds() / 1e6,
+		PositionMin: p.PositionMin,
+		PositionMax: p.PositionMax,
+		SpeedMin:    p.SpeedMin,
+		SpeedMax:    p.SpeedMax,
+		Algorithm:   p.Algorithm,
+		Invert:      p.Invert,
+	}
+	if err := json.Unmarshal(in, &c); err != nil {
+		return err
+	}
+	if !validAlgorithm(c.Algorithm) {
+		return ErrUnknownAlgorithm
+	}
+	p.Latency = time.Duration(c.Latency) * time.Millisecond
+	p.PositionMin = c.PositionMin
+	p.PositionMax = c.PositionMax
+	p.SpeedMin = c.SpeedMin
+	p.SpeedMax = c.SpeedMax
+	p.Algorithm = c.Algorithm
+	p.Invert = c.Invert
+	return nil
+}
+
+// personalizationJSON is the JSON representation of a Personalization, it uses
+// the same names and units as the play query parameters.
+type personalizationJSON struct {
+	Latency     int64  `json:"latency"` // in milliseconds
+	PositionMin int    `json:"positionmin"`
+	PositionMax int    `json:"positionmax"`
+	SpeedMin    int    `json:"speedmin"`
+	SpeedMax    int    `json:"speedmax"`
+	Algorithm   string `json:"algorithm,omitempty"`
+	Invert      bool   `json:"invert,omitempty"`
+}
+
+// ProfileStore keeps named Personalization profiles. Changes are persisted
+// to a JSON file when the store has one.
+type ProfileStore struct {
+	sync.Mutex
+
+	path     string
+	profiles map[string]Personalization
+}
+
+// NewProfileStore returns a store backed by the JSON file at path. Existing
+// profiles are loaded from the file, a missing file is created on the first
+// change. An empty path returns a store that is only kept in memory.
+func NewProfileStore(path string) (*ProfileStore, error) {
+	s := &ProfileStore{
+		path:     path,
+		profiles: make(map[string]Personalization),
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &s.profiles); err != nil {
+				return nil, err
+			}
+		}
+		for name, p := range s.profiles {
+			if err := p.Validate(); err != nil {
+				return nil, fmt.Errorf("profile %s: %v", name, err)
+			}
+		}
+	}
+	if _, ok := s.profiles[DefaultProfile]; !ok {
+		s.profiles[DefaultProfile] = NewPersonalization()
+	}
+	return s, nil
+}
+
+// Get returns the profile with the given name. The default profile is
+// returned when name is empty.
+func (s *ProfileStore) Get(name string) (Personalization, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := s.profiles[name]
+	if !ok {
+		return NewPersonalization(), ErrProfileNotFound
+	}
+	return p, nil
+}
+
+// Profiles returns a copy of all stored profiles by name.
+func (s *ProfileStore) Profiles() map[string]Personalization {
+	s.Lock()
+	defer s.Unlock()
+
+	profiles := make(map[string]Personalization, len(s.profiles))
+	for name, p := range s.profiles {
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// Set creates or replaces the profile with the given name. A
+// *PersonalizationError is returned when p is not valid.
+func (s *ProfileStore) Set(name string, p Personalization) error {
+	if !validProfileName(name) {
+		return ErrProfileName
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	s.profiles[name] = p
+	return s.save()
+}
+
+// Delete removes the profile with the given name. Deleting the default
+// profile resets it to the default personalization values.
+func (s *ProfileStore) Delete(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	if name == DefaultProfile {
+		s.profiles[name] = NewPersonalization()
+	} else {
+		delete(s.profiles, name)
+	}
+	return s.save()
+}
+
+// save writes all profiles to the stores file. The file is replaced
+// atomically so a crash will not leave a truncated file behind.
+func (s *ProfileStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.profiles, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".profiles")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// validProfileName returns true if name can be used as a profile name.
+func validProfileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/\\?#")
+}
@@ -0,0 +1,196 @@
+package control
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPersonalizationJSON(t *testing.T) {
+	want := Personalization{
+		Latency:     time.Millisecond * 150,
+		PositionMin: 10,
+		PositionMax: 90,
+		SpeedMin:    30,
+		SpeedMax:    70,
+		Algorithm:   "tempo",
+		Invert:      true,
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewPersonalization()
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("round trip does not match, want %+v, got %+v", want, got)
+	}
+
+	// Partial input keeps the other values.
+	got = NewPersonalization()
+	if err := json.Unmarshal([]byte(`{"latency":200}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	want = NewPersonalization()
+	want.Latency = time.Millisecond * 200
+	if got != want {
+		t.Errorf("partial update does not match, want %+v, got %+v", want, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"algorithm":"magic"}`), &got); err != ErrUnknownAlgorithm {
+		t.Errorf("unknown algorithm: want %v, got %v", ErrUnknownAlgorithm, err)
+	}
+}
+
+func TestProfileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+
+	s, err := NewProfileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := s.Get(""); err != nil || p != NewPersonalization() {
+		t.Errorf("default profile missing or changed: %+v, %v", p, err)
+	}
+	if _, err := s.Get("slow"); err != ErrProfileNotFound {
+		t.Errorf("unknown profile, want %v, got %v", ErrProfileNotFound, err)
+	}
+	if err := s.Set("a/b", NewPersonalization()); err != ErrProfileName {
+		t.Errorf("invalid name, want %v, got %v", ErrProfileName, err)
+	}
+	for _, invalid := range []func(p *Personalization){
+		func(p *Personalization) { p.Latency = -time.Millisecond },
+		func(p *Personalization) { p.PositionMin = 80; p.PositionMax = 20 },
+		func(p *Personalization) { p.PositionMax = 101 },
+		func(p *Personalization) { p.SpeedMin = -1 },
+		func(p *Personalization) { p.SpeedMin = 50; p.SpeedMax = 50 },
+	} {
+		p := NewPersonalization()
+		invalid(&p)
+		if err := s.Set("invalid", p); err == nil {
+			t.Errorf("invalid profile stored: %+v", p)
+		} else if _, ok := err.(*PersonalizationError); !ok {
+			t.Errorf("invalid profile, want PersonalizationError, got %v",
+				err)
+		}
+	}
+	if _, err := s.Get("invalid"); err != ErrProfileNotFound {
+		t.Errorf("invalid profile stored")
+	}
+
+	slow := NewPersonalization()
+	slow.SpeedMax = 50
+	if err := s.Set("slow", slow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Profiles must survive a reload.
+	s, err = NewProfileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := s.Get("slow"); err != nil || p != slow {
+		t.Errorf("stored profile does not match, want %+v, got %+v (%v)",
+			slow, p, err)
+	}
+	if len(s.Profiles()) != 2 {
+		t.Errorf("expected 2 profiles, got %d", len(s.Profiles()))
+	}
+
+	if err := s.Delete("slow"); err != nil {
+		t.Error(err)
+	}
+	if err := s.Delete("slow"); err != ErrProfileNotFound {
+		t.Errorf("delete twice, want %v, got %v", ErrProfileNotFound, err)
+	}
+}
+
+func TestProfilesHandler(t *testing.T) {
+	c := NewController(nil)
+	var tests = []struct {
+		Method string
+		Body   string
+		Code   int
+	}{
+		{"PUT", `{"latency":100}`, http.StatusOK},
+		{"PUT", `{"latency":-100}`, http.StatusBadRequest},
+		{"PUT", `{"positionmin":80,"positionmax":20}`, http.StatusBadRequest},
+		{"PUT", `{"speedmax":101}`, http.StatusBadRequest},
+		{"PUT", `{`, http.StatusBadRequest},
+		{"PATCH", `{"speedmax":60}`, http.StatusOK},
+		{"PATCH", `{"speedmin":60}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.Method, "/v1/profiles/test",
+			strings.NewReader(tt.Body))
+		w := httptest.NewRecorder()
+		c.ProfilesHandler(w, r)
+		if w.Code != tt.Code {
+			t.Errorf("%s %s, want %d, got %d", tt.Method, tt.Body,
+				tt.Code, w.Code)
+		}
+	}
+	want := NewPersonalization()
+	want.Latency = 100 * time.Millisecond
+	want.SpeedMax = 60
+	if p, err := c.profiles.Get("test"); err != nil {
+		t.Error(err)
+	} else if p != want {
+		t.Errorf("patched profile, want %+v, got %+v", want, p)
+	}
+
+	// PUT replaces the whole profile.
+	r := httptest.NewRequest("PUT", "/v1/profiles/test",
+		strings.NewReader(`{"speedmin":20}`))
+	c.ProfilesHandler(httptest.NewRecorder(), r)
+	want = NewPersonalization()
+	want.SpeedMin = 20
+	if p, err := c.profiles.Get("test"); err != nil {
+		t.Error(err)
+	} else if p != want {
+		t.Errorf("replaced profile, want %+v, got %+v", want, p)
+	}
+
+	r = httptest.NewRequest("PATCH", "/v1/profiles/missing",
+		strings.NewReader(`{"speedmin":20}`))
+	w := httptest.NewRecorder()
+	c.ProfilesHandler(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("patch missing profile, want %d, got %d",
+			http.StatusNotFound, w.Code)
+	}
+}
+
+func TestParsePlayParams(t *testing.T) {
+	profile := NewPersonalization()
+	profile.Latency = time.Millisecond * 100
+	profile.SpeedMax = 60
+
+	q, _ := url.ParseQuery(
+		"speedmax=70&positionmin=10&algorithm=position&invert=1")
+	got := parsePlayParams(q, profile)
+
+	want := profile
+	want.SpeedMax = 70
+	want.PositionMin = 10
+	want.Algorithm = "position"
+	want.Invert = true
+	if got != want {
+		t.Errorf("query did not override profile, want %+v, got %+v",
+			want, got)
+	}
+}
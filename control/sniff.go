@@ -0,0 +1,155 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Scores returned by the sniffers of the Loaders.
+const (
+	sniffMismatch = -1 // content can not be in the format
+	sniffUnknown  = 0  // content might be in the format
+	sniffLikely   = 1  // content has the structure of the format
+	sniffCertain  = 2  // content has the signature of the format
+)
+
+// candidate is a loader with the score of the content.
+type candidate struct {
+	loader Loader
+	score  int
+}
+
+// sniff scores the loaders for data and returns them from the most to the
+// least likely. Loaders with the same score keep their order.
+func sniff(loaders []Loader, data []byte) []candidate {
+	c := make([]candidate, len(loaders))
+	for i, l := range loaders {
+		c[i].loader = l
+		if l.Sniff != nil {
+			c[i].score = l.Sniff(data)
+		}
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].score > c[j].score
+	})
+	return c
+}
+
+// firstByte returns the first non whitespace byte of data, or 0 if there
+// is none.
+func firstByte(data []byte) byte {
+	data = bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(data) == 0 {
+		return 0
+	}
+	return data[0]
+}
+
+// jsonKeys returns the keys of the JSON object in data, or nil if data is
+// not a JSON object.
+func jsonKeys(data []byte) map[string]bool {
+	if firstByte(data) != '{' {
+		return nil
+	}
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	keys := make(map[string]bool, len(v))
+	for k := range v {
+		keys[k] = true
+	}
+	return keys
+}
+
+// sniffJSONObject scores data as a JSON object with one of the keys.
+func sniffJSONObject(data []byte, keys ...string) int {
+	found := jsonKeys(data)
+	if found == nil {
+		return sniffMismatch
+	}
+	for _, k := range keys {
+		if found[k] {
+			return sniffCertain
+		}
+	}
+	return sniffUnknown
+}
+
+// sniffFunscript scores data as a Funscript.
+func sniffFunscript(data []byte) int {
+	return sniffJSONObject(data, "actions")
+}
+
+// sniffRaw scores data as a raw script.
+func sniffRaw(data []byte) int {
+	if firstByte(data) != '[' {
+		return sniffMismatch
+	}
+	return sniffLikely
+}
+
+// kiirooEvent matches the start of a Kiiroo script ({1.00:1,...), the
+// braces are optional.
+var kiirooEvent = regexp.MustCompile(`^\{?\s*([0-9]+(\.[0-9]*)?|\.[0-9]+)\s*:`)
+
+// sniffKiiroo scores data as a time:value Kiiroo script.
+func sniffKiiroo(data []byte) int {
+	if kiirooEvent.Match(bytes.TrimLeft(data, " \t\r\n\ufeff")) {
+		return sniffCertain
+	}
+	return sniffMismatch
+}
+
+// sniffKiirooText scores data as an INI file with a Kiiroo section.
+func sniffKiirooText(data []byte) int {
+	sections := iniSections(data)
+	if sections == nil {
+		return sniffMismatch
+	}
+	if sections["kiiroo"] {
+		return sniffCertain
+	}
+	return sniffLikely
+}
+
+// iniSections returns the (lowercase) names of the sections in the INI file
+// data, or nil when data has no sections.
+func iniSections(data []byte) map[string]bool {
+	var sections map[string]bool
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 2 && line[0] == '[' && line[len(line)-1] == ']' &&
+			!strings.ContainsAny(line, "{}\":,") {
+			if sections == nil {
+				sections = make(map[string]bool)
+			}
+			sections[strings.ToLower(line[1:len(line)-1])] = true
+		}
+	}
+	return sections
+}
+
+// sniffKiirooJSON scores data as a FeelMe Kiiroo JSON file.
+func sniffKiirooJSON(data []byte) int {
+	return sniffJSONObject(data, "text", "subs")
+}
+
+// sniffBundle scores data as a zip archive.
+func sniffBundle(data []byte) int {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) ||
+		bytes.HasPrefix(data, []byte("PK\x05\x06")) {
+		return sniffCertain
+	}
+	return sniffMismatch
+}
+
+// sniffPattern scores data as a pattern.
+func sniffPattern(data []byte) int {
+	return sniffJSONObject(data, "waveform")
+}
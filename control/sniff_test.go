@@ -0,0 +1,123 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	cases := []struct {
+		Data  string
+		First string
+	}{
+		{`{"version":"1.0","actions":[{"at":100,"pos":50}]}`, "funscript"},
+		{`[{"at":100,"pos":50,"spd":50}]`, "raw"},
+		{` {1.00:1,2.50:4}`, "kiiroo"},
+		{"1.00 : 1\n2.50 : 4\n", "kiiroo"},
+		{"[Player]\nzoom=0\n[Kiiroo]\nonyx=1.0,1;2.5,4\n", "kiiroo-text"},
+		{`{"subs":{"text":"{1.00:1}"}}`, "kiiroo-json"},
+		{"PK\x03\x04", "zip"},
+		{`{"waveform":"sine","tempo":60}`, "pattern"},
+	}
+	for _, c := range cases {
+		candidates := sniff(Loaders, []byte(c.Data))
+		if got := candidates[0].loader.Name; got != c.First {
+			t.Errorf("%q: want %s first, got %s", c.Data, c.First, got)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"funscript":   `[1,2]`,
+		"raw":         `{"actions":[]}`,
+		"kiiroo":      `{"text":"{1.00:1}"}`,
+		"kiiroo-text": `{1.00:1}`,
+		"zip":         `{}`,
+	} {
+		for _, c := range sniff(Loaders, []byte(data)) {
+			if c.loader.Name == name && c.score >= 0 {
+				t.Errorf("%s: %q scored %d", name, data, c.score)
+			}
+		}
+	}
+}
+
+func TestLoadScriptReport(t *testing.T) {
+	data := `{"text":"{1.00:1,2.00:4}"}`
+	_, report, err := LoadScriptReport(bytes.NewBufferString(data),
+		"application/json", NewPersonalization())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Loader != "kiiroo-json" {
+		t.Errorf("want kiiroo-json loader, got %s", report.Loader)
+	}
+	// Tried first because of the text key
+	if len(report.Errors) != 0 {
+		t.Errorf("want no failed loaders, got %+v", report.Errors)
+	}
+
+	_, report, err = LoadScriptReport(bytes.NewBufferString(`{"actions":1}`),
+		"application/json", NewPersonalization())
+	if err != ErrUnsupported {
+		t.Fatalf("want ErrUnsupported, got %v", err)
+	}
+	want := []struct {
+		Loader  string
+		Skipped bool
+	}{
+		{"funscript", false},
+		{"kiiroo-json", false},
+		{"raw", true},
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("want %d failed loaders, got %+v", len(want),
+			report.Errors)
+	}
+	for i, w := range want {
+		e := report.Errors[i]
+		if e.Loader != w.Loader || (e.Error == errNoMatch.Error()) != w.Skipped {
+			t.Errorf("error %d: want %s (skipped %t), got %+v", i,
+				w.Loader, w.Skipped, e)
+		}
+	}
+}
+
+func TestLoadRequestUnsupported(t *testing.T) {
+	cases := []struct {
+		ContentType string
+		Single      bool // only one loader supports the content type
+	}{
+		{funscriptMediaType, true},
+		{"application/json", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("POST", "/v1/play",
+			bytes.NewBufferString(`{"actions":1}`))
+		r.Header.Set("Content-Type", tc.ContentType)
+		w := httptest.NewRecorder()
+		if _, ok := NewController(nil).loadRequest(w, r); ok ||
+			w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("%s: want status %d, got %d", tc.ContentType,
+				http.StatusUnsupportedMediaType, w.Code)
+			continue
+		}
+		var v struct {
+			Error   string
+			Loaders []LoaderError
+		}
+		if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		want := ErrUnsupported.Error()
+		if tc.Single && len(v.Loaders) == 1 {
+			want = v.Loaders[0].Error
+		}
+		if v.Error != want || (tc.Single && v.Error == ErrUnsupported.Error()) {
+			t.Errorf("%s: want error %q, got %q", tc.ContentType, want,
+				v.Error)
+		}
+	}
+}
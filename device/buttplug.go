@@ -0,0 +1,356 @@
+package device
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// buttplugMessageVersion is the version of the buttplug.io message spec used.
+const buttplugMessageVersion = 1
+
+// ButtplugVibrator drives vibrating toys connected to a buttplug.io websocket
+// server. It is a Device with the Vibrate capability, vibrations are send to
+// all vibrators known by the server.
+//
+// The LaunchManager converts the moves of stroker scripts into vibrations
+// using its VibrationCurve.
+type ButtplugVibrator struct {
+	sync.Mutex
+
+	address string
+	name    string
+	tlscfg  *tls.Config
+
+	conn    *websocket.Conn
+	done    chan struct{} // closed when conn is closed
+	msgID   uint32
+	devices map[int]int // vibrate feature count by device index
+
+	disconnectFunc func()
+}
+
+// NewButtplugVibrator returns a vibrator for the buttplug.io server at
+// address, identifying itself as name.
+func NewButtplugVibrator(address, name string, tlscfg *tls.Config) *ButtplugVibrator {
+	return &ButtplugVibrator{
+		address: address,
+		name:    name,
+		tlscfg:  tlscfg,
+		devices: make(map[int]int),
+	}
+}
+
+// Buttplug.io protocol messages.
+type (
+	bpID struct {
+		ID uint32 `json:"Id"`
+	}
+	bpRequestServerInfo struct {
+		ID             uint32 `json:"Id"`
+		ClientName     string
+		MessageVersion int
+	}
+	bpServerInfo struct {
+		ID          uint32 `json:"Id"`
+		MaxPingTime int
+	}
+	bpDevice struct {
+		ID             uint32 `json:"Id,omitempty"`
+		DeviceName     string
+		DeviceIndex    int
+		DeviceMessages map[string]struct {
+			FeatureCount int
+		}
+	}
+	bpDeviceList struct {
+		ID      uint32 `json:"Id"`
+		Devices []bpDevice
+	}
+	bpVibrateCmd struct {
+		ID          uint32 `json:"Id"`
+		DeviceIndex int
+		Speeds      []bpSpeed
+	}
+	bpSpeed struct {
+		Index int
+		Speed float64
+	}
+	bpError struct {
+		ID           uint32 `json:"Id"`
+		ErrorMessage string
+	}
+)
+
+// Connect connects to the buttplug.io server and starts scanning for
+// devices.
+func (b *ButtplugVibrator) Connect(ctx context.Context) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.conn != nil {
+		return nil
+	}
+	d := websocket.Dialer{TLSClientConfig: b.tlscfg}
+	conn, _, err := d.DialContext(ctx, b.address, nil)
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(dl)
+	}
+	pingTime, err := b.handshake(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	b.conn = conn
+	b.done = make(chan struct{})
+	go b.readLoop(conn)
+	if pingTime > 0 {
+		go b.pingLoop(b.done, pingTime/2)
+	}
+	log.Printf("Buttplug connected to %s (%d vibrators)", b.address,
+		len(b.devices))
+	return nil
+}
+
+// handshake identifies the client, retrieves the connected devices and starts
+// scanning for new ones. It returns the ping interval required by the server.
+func (b *ButtplugVibrator) handshake(conn *websocket.Conn) (time.Duration, error) {
+	var info bpServerInfo
+	err := b.request(conn, "RequestServerInfo", &bpRequestServerInfo{
+		ClientName:     b.name,
+		MessageVersion: buttplugMessageVersion,
+	}, "ServerInfo", &info)
+	if err != nil {
+		return 0, err
+	}
+	var list bpDeviceList
+	if err := b.request(conn, "RequestDeviceList", &bpID{}, "DeviceList", &list); err != nil {
+		return 0, err
+	}
+	b.devices = make(map[int]int)
+	for _, d := range list.Devices {
+		b.addDevice(d)
+	}
+	if err := b.request(conn, "StartScanning", &bpID{}, "Ok", nil); err != nil {
+		return 0, err
+	}
+	return time.Duration(info.MaxPingTime) * time.Millisecond, nil
+}
+
+// request sends a message and reads messages until the reply of type want
+// is received, which is decoded into v.
+func (b *ButtplugVibrator) request(conn *websocket.Conn, typ string, msg interface{}, want string, v interface{}) error {
+	id, err := b.write(conn, typ, msg)
+	if err != nil {
+		return err
+	}
+	for {
+		msgs, err := readButtplug(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			for t, data := range m {
+				var reply bpID
+				json.Unmarshal(data, &reply)
+				if reply.ID != id {
+					b.handle(t, data)
+					continue
+				}
+				if t == "Error" {
+					var e bpError
+					json.Unmarshal(data, &e)
+					return fmt.Errorf("buttplug: %s", e.ErrorMessage)
+				}
+				if t != want {
+					return fmt.Errorf("buttplug: unexpected %s reply to %s", t, typ)
+				}
+				if v != nil {
+					return json.Unmarshal(data, v)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// write sends a message of type typ with a new id and returns the id.
+func (b *ButtplugVibrator) write(conn *websocket.Conn, typ string, msg interface{}) (uint32, error) {
+	b.msgID++
+	id := b.msgID
+	switch m := msg.(type) {
+	case *bpID:
+		m.ID = id
+	case *bpRequestServerInfo:
+		m.ID = id
+	case *bpVibrateCmd:
+		m.ID = id
+	default:
+		return 0, errors.New("buttplug: unknown message type")
+	}
+	return id, conn.WriteJSON([]map[string]interface{}{{typ: msg}})
+}
+
+// readButtplug reads a single websocket message containing buttplug
+// messages.
+func readButtplug(conn *websocket.Conn) ([]map[string]json.RawMessage, error) {
+	var msgs []map[string]json.RawMessage
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// handle processes unsolicited messages from the server.
+func (b *ButtplugVibrator) handle(typ string, data json.RawMessage) {
+	switch typ {
+	case "DeviceAdded":
+		var d bpDevice
+		if err := json.Unmarshal(data, &d); err == nil {
+			b.addDevice(d)
+		}
+	case "DeviceRemoved":
+		var d bpDevice
+		if err := json.Unmarshal(data, &d); err == nil {
+			delete(b.devices, d.DeviceIndex)
+		}
+	case "Error":
+		var e bpError
+		json.Unmarshal(data, &e)
+		log.Printf("Buttplug error: %s", e.ErrorMessage)
+	}
+}
+
+// addDevice registers d if it can vibrate.
+func (b *ButtplugVibrator) addDevice(d bpDevice) {
+	if cmd, ok := d.DeviceMessages["VibrateCmd"]; ok {
+		count := cmd.FeatureCount
+		if count < 1 {
+			count = 1
+		}
+		b.devices[d.DeviceIndex] = count
+		log.Printf("Buttplug vibrator added: %s", d.DeviceName)
+	}
+}
+
+// readLoop handles messages from the server until the connection closes.
+func (b *ButtplugVibrator) readLoop(conn *websocket.Conn) {
+	for {
+		msgs, err := readButtplug(conn)
+		b.Lock()
+		if err != nil {
+			if b.conn == conn {
+				log.Printf("Buttplug connection lost: %v", err)
+				b.close()
+			}
+			b.Unlock()
+			return
+		}
+		for _, m := range msgs {
+			for t, data := range m {
+				b.handle(t, data)
+			}
+		}
+		b.Unlock()
+	}
+}
+
+// pingLoop keeps the connection alive until done is closed.
+func (b *ButtplugVibrator) pingLoop(done <-chan struct{}, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			b.Lock()
+			if b.conn != nil {
+				if _, err := b.write(b.conn, "Ping", &bpID{}); err != nil {
+					b.close()
+				}
+			}
+			b.Unlock()
+		}
+	}
+}
+
+// Disconnect stops all devices and closes the connection to the server.
+func (b *ButtplugVibrator) Disconnect() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.conn == nil {
+		return
+	}
+	b.write(b.conn, "StopAllDevices", &bpID{})
+	b.close()
+}
+
+// HandleDisconnect registers a function that is called when the connection to
+// the server is lost.
+func (b *ButtplugVibrator) HandleDisconnect(fnc func()) {
+	b.Lock()
+	defer b.Unlock()
+	b.disconnectFunc = fnc
+}
+
+// Capabilities implements Device.
+func (b *ButtplugVibrator) Capabilities() Capability {
+	return Vibrate
+}
+
+// Vibrate sets all vibrators to intensity (0.0-1.0.)
+func (b *ButtplugVibrator) Vibrate(intensity float64) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.conn == nil {
+		return
+	}
+	for index, features := range b.devices {
+		cmd := bpVibrateCmd{
+			DeviceIndex: index,
+			Speeds:      make([]bpSpeed, features),
+		}
+		for i := range cmd.Speeds {
+			cmd.Speeds[i] = bpSpeed{Index: i, Speed: intensity}
+		}
+		if _, err := b.write(b.conn, "VibrateCmd", &cmd); err != nil {
+			log.Printf("Buttplug write error: %v", err)
+			b.close()
+			return
+		}
+	}
+}
+
+// close closes the connection and notifies the disconnect handler.
+func (b *ButtplugVibrator) close() {
+	if b.conn == nil {
+		return
+	}
+	b.conn.Close()
+	b.conn = nil
+	close(b.done)
+	if b.disconnectFunc != nil {
+		// Vibrate may be blocked on the lock held here while the
+		// handler stops playback, so it can't run until close returns.
+		go b.disconnectFunc()
+	}
+}
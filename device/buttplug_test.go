@@ -0,0 +1,131 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeButtplug is a buttplug.io websocket server with one vibrator that
+// records all received vibrate commands.
+type fakeButtplug struct {
+	sync.Mutex
+
+	Speeds []float64
+	Stops  int
+}
+
+func (f *fakeButtplug) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var upgrader websocket.Upgrader
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		var msgs []map[string]json.RawMessage
+		if err := conn.ReadJSON(&msgs); err != nil {
+			return
+		}
+		for _, m := range msgs {
+			for typ, data := range m {
+				var id bpID
+				json.Unmarshal(data, &id)
+				var reply interface{}
+				switch typ {
+				case "RequestServerInfo":
+					reply = map[string]interface{}{"ServerInfo": map[string]interface{}{
+						"Id": id.ID, "ServerName": "fake",
+						"MessageVersion": 1, "MaxPingTime": 0,
+					}}
+				case "RequestDeviceList":
+					reply = map[string]interface{}{"DeviceList": map[string]interface{}{
+						"Id": id.ID,
+						"Devices": []interface{}{map[string]interface{}{
+							"DeviceName":  "Fake Vibrator",
+							"DeviceIndex": 3,
+							"DeviceMessages": map[string]interface{}{
+								"VibrateCmd":    map[string]int{"FeatureCount": 2},
+								"StopDeviceCmd": map[string]int{},
+							},
+						}},
+					}}
+				case "VibrateCmd":
+					var cmd bpVibrateCmd
+					json.Unmarshal(data, &cmd)
+					f.Lock()
+					if cmd.DeviceIndex == 3 && len(cmd.Speeds) == 2 {
+						f.Speeds = append(f.Speeds, cmd.Speeds[0].Speed)
+					}
+					f.Unlock()
+					reply = map[string]interface{}{"Ok": id}
+				case "StopAllDevices":
+					f.Lock()
+					f.Stops++
+					f.Unlock()
+					reply = map[string]interface{}{"Ok": id}
+				default:
+					reply = map[string]interface{}{"Ok": id}
+				}
+				conn.WriteJSON([]interface{}{reply})
+			}
+		}
+	}
+}
+
+func TestButtplugVibrator(t *testing.T) {
+	fake := &fakeButtplug{}
+	s := httptest.NewServer(fake)
+	defer s.Close()
+
+	b := NewButtplugVibrator("ws"+strings.TrimPrefix(s.URL, "http"),
+		"test", nil)
+	disconnected := make(chan struct{})
+	b.HandleDisconnect(func() {
+		close(disconnected)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Vibrate(1)
+	b.Vibrate(0)
+	b.Disconnect()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Errorf("disconnect handler not called")
+	}
+
+	// Give the server some time to process the last message
+	for i := 0; i < 10; i++ {
+		fake.Lock()
+		stops := fake.Stops
+		fake.Unlock()
+		if stops > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	fake.Lock()
+	defer fake.Unlock()
+	if len(fake.Speeds) != 2 {
+		t.Fatalf("expected 2 vibrate commands, got %d", len(fake.Speeds))
+	}
+	if fake.Speeds[0] < 0.99 || fake.Speeds[1] != 0 {
+		t.Errorf("unexpected vibration speeds: %v", fake.Speeds)
+	}
+	if fake.Stops != 1 {
+		t.Errorf("devices were not stopped on disconnect")
+	}
+}
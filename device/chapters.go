@@ -0,0 +1,179 @@
+package device
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// ErrChapterNotFound is returned when the requested chapter or bookmark does
+// not exist in the loaded script.
+var ErrChapterNotFound = errors.New("chapter not found")
+
+// chapterRestart is how far into a chapter PreviousChapter goes back to the
+// start of the chapter instead of to the chapter before it.
+const chapterRestart = time.Second * 3
+
+// ChapterStatus describes the chapters and bookmarks of the loaded script.
+type ChapterStatus struct {
+	Current   int                 `json:"current"`
+	Chapters  []protocol.Chapter  `json:"chapters"`
+	Bookmarks []protocol.Bookmark `json:"bookmarks"`
+}
+
+// Chapters returns the chapters and bookmarks of the loaded script sorted by
+// time. Current is the index of the chapter that is playing, or -1 when
+// there is none or the position is unknown.
+func (m *LaunchManager) Chapters() ChapterStatus {
+	m.Lock()
+	defer m.Unlock()
+
+	s := ChapterStatus{
+		Current:   -1,
+		Chapters:  m.chapters(),
+		Bookmarks: m.bookmarks(),
+	}
+	if p, ok := m.scriptPosition(); ok {
+		s.Current = currentChapter(s.Chapters, p)
+	}
+	return s
+}
+
+// SkipChapter jumps to the start of chapter n of the loaded script.
+func (m *LaunchManager) SkipChapter(n int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c := m.chapters()
+	if n < 0 || n >= len(c) {
+		return ErrChapterNotFound
+	}
+	return m.skip(c[n].Start)
+}
+
+// SkipBookmark jumps to bookmark n of the loaded script.
+func (m *LaunchManager) SkipBookmark(n int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	b := m.bookmarks()
+	if n < 0 || n >= len(b) {
+		return ErrChapterNotFound
+	}
+	return m.skip(b[n].Time)
+}
+
+// NextChapter jumps to the start of the first chapter after the playback
+// position.
+func (m *LaunchManager) NextChapter() error {
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.trackPosition()
+	if err != nil {
+		return err
+	}
+	for _, c := range m.chapters() {
+		if c.Start > p {
+			return m.skip(c.Start)
+		}
+	}
+	return ErrChapterNotFound
+}
+
+// PreviousChapter jumps back to the start of the playing chapter, or to the
+// chapter before it when the playing chapter has just started.
+func (m *LaunchManager) PreviousChapter() error {
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.trackPosition()
+	if err != nil {
+		return err
+	}
+	c := m.chapters()
+	i := len(c) - 1
+	for i >= 0 && c[i].Start > p {
+		i--
+	}
+	if i >= 0 && p-c[i].Start < chapterRestart {
+		i--
+	}
+	if i < 0 {
+		return ErrChapterNotFound
+	}
+	return m.skip(c[i].Start)
+}
+
+// chapters returns the chapters of the loaded script sorted by start time,
+// the caller must hold the lock.
+func (m *LaunchManager) chapters() []protocol.Chapter {
+	md := m.metadata()
+	if md == nil {
+		return []protocol.Chapter{}
+	}
+	c := append([]protocol.Chapter{}, md.Chapters...)
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Start < c[j].Start
+	})
+	return c
+}
+
+// bookmarks returns the bookmarks of the loaded script sorted by time, the
+// caller must hold the lock.
+func (m *LaunchManager) bookmarks() []protocol.Bookmark {
+	md := m.metadata()
+	if md == nil {
+		return []protocol.Bookmark{}
+	}
+	b := append([]protocol.Bookmark{}, md.Bookmarks...)
+	sort.SliceStable(b, func(i, j int) bool {
+		return b[i].Time < b[j].Time
+	})
+	return b
+}
+
+// scriptPosition returns the playback position of the loaded script. The
+// second return value is false when not playing or the player can not tell.
+// The caller must hold the lock.
+func (m *LaunchManager) scriptPosition() (time.Duration, bool) {
+	if !m.isPlaying() {
+		return 0, false
+	}
+	t, ok := m.player.(protocol.Trackable)
+	if !ok {
+		return 0, false
+	}
+	return t.Position(), true
+}
+
+// trackPosition is like scriptPosition but returns the error to report when
+// the position is unknown.
+func (m *LaunchManager) trackPosition() (time.Duration, error) {
+	if !m.isPlaying() {
+		return 0, ErrNotPlaying
+	}
+	p, ok := m.scriptPosition()
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return p, nil
+}
+
+// currentChapter returns the index of the chapter in c that contains
+// position p, or -1 when there is none. A chapter without an end lasts until
+// the next one starts.
+func currentChapter(c []protocol.Chapter, p time.Duration) int {
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].Start > p {
+			continue
+		}
+		if c[i].End > c[i].Start && p >= c[i].End {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func TestCurrentChapter(t *testing.T) {
+	chapters := []protocol.Chapter{
+		{Name: "one", Start: time.Second, End: time.Second * 2},
+		{Name: "two", Start: time.Second * 3},
+		{Name: "three", Start: time.Second * 5, End: time.Second * 6},
+	}
+	cases := []struct {
+		Position time.Duration
+		Current  int
+	}{
+		{0, -1},
+		{time.Second, 0},
+		{time.Second * 2, -1},
+		{time.Second * 4, 1},
+		{time.Second * 5, 2},
+		{time.Second * 7, -1},
+	}
+	for _, c := range cases {
+		if i := currentChapter(chapters, c.Position); i != c.Current {
+			t.Errorf("%s: want chapter %d, got %d", c.Position,
+				c.Current, i)
+		}
+	}
+}
+
+func TestChapterNavigation(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = protocol.TimedActions{{
+		Action: protocol.Action{Position: 50, Speed: 50},
+		Time:   time.Minute,
+	}}
+	p.Meta = &protocol.Metadata{
+		Chapters: []protocol.Chapter{
+			{Name: "last", Start: time.Second * 40},
+			{Name: "first", Start: time.Second * 10},
+			{Name: "middle", Start: time.Second * 20},
+		},
+		Bookmarks: []protocol.Bookmark{
+			{Name: "mark", Time: time.Second * 30},
+		},
+	}
+	lm.SetScriptPlayer(p)
+
+	if err := lm.NextChapter(); err != ErrNotPlaying {
+		t.Errorf("next when stopped: want ErrNotPlaying, got %v", err)
+	}
+	if s := lm.Chapters(); s.Current != -1 || len(s.Chapters) != 3 ||
+		s.Chapters[0].Name != "first" || len(s.Bookmarks) != 1 {
+		t.Errorf("unexpected chapters: %+v", s)
+	}
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	defer lm.Stop()
+	time.Sleep(time.Millisecond * 10)
+
+	steps := []struct {
+		Name    string
+		Do      func() error
+		Err     error
+		Current int
+	}{
+		{"next", lm.NextChapter, nil, 0},
+		{"next", lm.NextChapter, nil, 1},
+		{"previous", lm.PreviousChapter, nil, 0},
+		{"previous", lm.PreviousChapter, ErrChapterNotFound, 0},
+		{"chapter 2", func() error { return lm.SkipChapter(2) }, nil, 2},
+		{"next", lm.NextChapter, ErrChapterNotFound, 2},
+		{"chapter 3", func() error { return lm.SkipChapter(3) }, ErrChapterNotFound, 2},
+		{"bookmark 0", func() error { return lm.SkipBookmark(0) }, nil, 1},
+		{"bookmark 1", func() error { return lm.SkipBookmark(1) }, ErrChapterNotFound, 1},
+		// More than a few seconds in restarts the chapter
+		{"previous", lm.PreviousChapter, nil, 1},
+	}
+	for _, s := range steps {
+		if err := s.Do(); err != s.Err {
+			t.Errorf("%s: want error %v, got %v", s.Name, s.Err, err)
+		}
+		time.Sleep(time.Millisecond * 10)
+		if c := lm.Chapters(); c.Current != s.Current {
+			t.Errorf("%s: want chapter %d, got %d", s.Name,
+				s.Current, c.Current)
+		}
+	}
+	want := time.Second * 20
+	if pos := p.Position(); pos < want || pos > want+time.Second {
+		t.Errorf("want position %s, got %s", want, pos)
+	}
+}
@@ -3,11 +3,12 @@ package device
 import (
 	"context"
 	"errors"
+	"log"
 	"sync"
 	"time"
 
-	"github.com/funjack/golaunch"
 	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
 )
 
 var (
@@ -22,53 +23,285 @@ var (
 // ConnectionTimeout is the default timeout used per Launch connecting attempt.
 var ConnectionTimeout = time.Second * 10
 
+// Park positions for SetPark.
+const (
+	// ParkOff disables parking.
+	ParkOff = -1
+	// ParkBottom is the lowest position.
+	ParkBottom = 0
+	// ParkTop is the highest position.
+	ParkTop = 99
+
+	// DefaultParkSpeed is the safe speed used to move to the park
+	// position.
+	DefaultParkSpeed = 20
+)
+
 // LaunchManager is responsible for connecting and communicating with the
-// Launch.
+// Launch, or any other output Device.
 type LaunchManager struct {
 	sync.Mutex
 
-	launch      golaunch.Launch
-	isConnected bool
+	device       Device
+	mover        LinearDevice     // receives all moves
+	mapper       *intensityMapper // set when moves are converted
+	governor     *Governor        // enforces safety limits on mover
+	isConnected  bool
+	disconnected bool // connection was lost since the last connect
 
-	wg     sync.WaitGroup
-	player protocol.Player
+	wg      sync.WaitGroup
+	player  protocol.Player
+	queue   []queueItem
+	current int // index of player in queue
 
-	tracers sync.Map
+	tracersMux sync.Mutex
+	tracers    map[chan interface{}]bool
+
+	listenersMux sync.Mutex
+	listeners    map[chan Event]bool
 
 	playingMux sync.Mutex
 	playing    bool
+	paused     bool
+	position   int    // last position moved to
+	parkOnEnd  bool   // park when playback ends
+	ended      bool   // player finished, parking
+	generation uint64 // increased on every playback change
+
+	parkPosition int
+	parkSpeed    int
+	parked       time.Time // time the last park move completes
+
+	watchdog watchdog
+}
+
+// Status is the state of the manager.
+type Status struct {
+	Connected     bool               `json:"connected"`
+	Playing       bool               `json:"playing"`
+	Paused        bool               `json:"paused"`
+	Capabilities  string             `json:"capabilities"`
+	EmergencyStop bool               `json:"estop"`
+	Watchdog      WatchdogStatus     `json:"watchdog"`
+	Metadata      *protocol.Metadata `json:"metadata,omitempty"`
 }
 
-// NewLaunchManager creates a new manager for the given Launch.
-func NewLaunchManager(l golaunch.Launch) *LaunchManager {
+// NewLaunchManager creates a new manager for the given Device. Moves are send
+// as is to Linear devices, devices that can only Vibrate or Rotate receive an
+// intensity based on the speed and length of the moves instead.
+func NewLaunchManager(d Device) *LaunchManager {
 	lm := &LaunchManager{
-		launch: l,
+		device:       d,
+		tracers:      make(map[chan interface{}]bool),
+		listeners:    make(map[chan Event]bool),
+		parkPosition: ParkOff,
+		parkSpeed:    DefaultParkSpeed,
 	}
-	lm.launch.HandleDisconnect(func() {
+	lm.watchdog.expire = lm.watchdogExpired
+	lm.mover = lm.dispatcher(d)
+	if lm.mover != nil {
+		lm.governor = NewGovernor(lm.mover, DefaultSafetyLimits,
+			func(e Event) { lm.trace(e) })
+	}
+	lm.device.HandleDisconnect(func() {
 		lm.Lock()
 		defer lm.Unlock()
 		// TODO implement nice reconnect handling
+		disconnectsTotal.Inc()
 		lm.isConnected = false
-		lm.player.Stop()
+		lm.disconnected = true
+		lm.setParkOnEnd(false)
+		lm.cancelAdvance()
+		if lm.player != nil {
+			countTimeout(lm.player.Stop())
+		}
 		lm.wg.Wait()
 	})
 
 	return lm
 }
 
-// SetScriptPlayer switches the active ScriptPlayer. Any active script will be
-// stopped.
+// dispatcher returns where moves are send to based on the capabilities of d,
+// or nil if d has no capability able to play moves.
+func (m *LaunchManager) dispatcher(d Device) LinearDevice {
+	caps := d.Capabilities()
+	if l, ok := d.(LinearDevice); ok && caps.Has(Linear) {
+		return l
+	}
+	var outputs []func(float64, bool)
+	if v, ok := d.(Vibrator); ok && caps.Has(Vibrate) {
+		outputs = append(outputs, func(i float64, _ bool) {
+			v.Vibrate(i)
+		})
+	}
+	if r, ok := d.(Rotator); ok && caps.Has(Rotate) {
+		outputs = append(outputs, r.Rotate)
+	}
+	if len(outputs) == 0 {
+		return nil
+	}
+	m.mapper = newIntensityMapper(DefaultVibrationCurve, func(i float64, up bool) {
+		for _, o := range outputs {
+			o(i, up)
+		}
+	})
+	return m.mapper
+}
+
+// Capabilities returns the capabilities of the managed device.
+func (m *LaunchManager) Capabilities() Capability {
+	return m.device.Capabilities()
+}
+
+// SetVibrationCurve sets the curve used to convert moves for devices that
+// vibrate or rotate.
+func (m *LaunchManager) SetVibrationCurve(c VibrationCurve) {
+	m.Lock()
+	defer m.Unlock()
+	if m.mapper != nil {
+		m.mapper.SetCurve(c)
+	}
+}
+
+// SetSafetyLimits changes the limits enforced on all moves.
+func (m *LaunchManager) SetSafetyLimits(l SafetyLimits) {
+	if m.governor != nil {
+		m.governor.SetLimits(l)
+	}
+}
+
+// EmergencyStop stops playback and refuses all moves and playback until
+// ClearEmergencyStop is called.
+func (m *LaunchManager) EmergencyStop() error {
+	if m.governor == nil {
+		return ErrNotSupported
+	}
+	// Drop moves right away, stopping the player may take a while.
+	m.governor.EmergencyStop()
+	log.Println("Emergency stop engaged")
+	m.publish(Event{
+		Type:    EventEmergencyStop,
+		Message: "emergency stop engaged",
+		Time:    time.Now(),
+	})
+	return m.Stop()
+}
+
+// ClearEmergencyStop releases the emergency stop.
+func (m *LaunchManager) ClearEmergencyStop() {
+	if m.governor == nil || !m.governor.Stopped() {
+		return
+	}
+	m.governor.Clear()
+	log.Println("Emergency stop cleared")
+	m.publish(Event{
+		Type:    EventEmergencyStop,
+		Message: "emergency stop cleared",
+		Time:    time.Now(),
+	})
+}
+
+// EmergencyStopped returns true if the emergency stop is engaged.
+func (m *LaunchManager) EmergencyStopped() bool {
+	return m.governor != nil && m.governor.Stopped()
+}
+
+// SetWatchdog configures the manager to require heartbeats while playing. If
+// no heartbeat is received within timeout the action (WatchdogPause or
+// WatchdogStop) is taken. A timeout of 0 disables the watchdog.
+func (m *LaunchManager) SetWatchdog(timeout time.Duration, action string) {
+	m.watchdog.Configure(timeout, action)
+}
+
+// Heartbeat signals the watchdog that the client controlling playback is
+// still alive.
+func (m *LaunchManager) Heartbeat() {
+	m.watchdog.Beat()
+}
+
+// watchdogExpired halts playback because no heartbeat was received in time.
+func (m *LaunchManager) watchdogExpired(action string) {
+	var err error
+	if action == WatchdogPause {
+		err = m.Pause()
+	}
+	if action != WatchdogPause || err == ErrNotSupported {
+		action = WatchdogStop
+		err = m.Stop()
+	}
+	if err == ErrNotPlaying {
+		return
+	} else if err != nil {
+		log.Printf("Watchdog: error halting playback: %v", err)
+		return
+	}
+	watchdogExpiredTotal.Inc()
+	msg := "no heartbeat received, playback paused"
+	if action == WatchdogStop {
+		msg = "no heartbeat received, playback stopped"
+	}
+	log.Printf("Watchdog: %s", msg)
+	m.publish(Event{
+		Type:    EventWatchdog,
+		Message: msg,
+		Time:    time.Now(),
+	})
+}
+
+// Status returns the state of the manager.
+func (m *LaunchManager) Status() Status {
+	m.Lock()
+	defer m.Unlock()
+	m.playingMux.Lock()
+	defer m.playingMux.Unlock()
+	return Status{
+		Connected:     m.isConnected,
+		Playing:       m.playing,
+		Paused:        m.paused,
+		Capabilities:  m.device.Capabilities().String(),
+		EmergencyStop: m.EmergencyStopped(),
+		Watchdog:      m.watchdog.Status(),
+		Metadata:      m.metadata(),
+	}
+}
+
+// SetPark configures the position (0-99) linear devices move to with speed
+// when playback is stopped or the script ends. Use ParkOff as position to
+// leave the device where the last move left it.
+func (m *LaunchManager) SetPark(position, speed int) {
+	m.Lock()
+	defer m.Unlock()
+
+	if position != ParkOff {
+		position = clampPosition(position)
+	}
+	if speed <= 0 {
+		speed = DefaultParkSpeed
+	}
+	m.playingMux.Lock()
+	m.parkPosition = position
+	m.parkSpeed = speed
+	m.playingMux.Unlock()
+}
+
+// SetScriptPlayer switches the active ScriptPlayer, replacing the queue. Any
+// active script will be stopped.
 func (m *LaunchManager) SetScriptPlayer(p protocol.Player) error {
 	m.Lock()
 	defer m.Unlock()
 
+	m.cancelAdvance()
 	if m.isPlaying() {
-		if err := m.player.Stop(); err != nil {
+		// The new script moves the device, no need to park first.
+		m.setParkOnEnd(false)
+		if err := m.stopPlayer(); err != nil {
 			return err
 		}
 		m.wg.Wait()
 	}
 	m.player = p
+	m.queue = []queueItem{{player: p}}
+	m.current = 0
 	return nil
 }
 
@@ -80,9 +313,15 @@ func (m *LaunchManager) connect() error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), ConnectionTimeout)
 	defer cancel()
-	if err := m.launch.Connect(ctx); err != nil {
+	connectAttemptsTotal.Inc()
+	if err := m.device.Connect(ctx); err != nil {
+		connectFailuresTotal.Inc()
 		return err
 	}
+	if m.disconnected {
+		reconnectsTotal.Inc()
+		m.disconnected = false
+	}
 
 	m.isConnected = true
 	return nil
@@ -93,10 +332,20 @@ func (m *LaunchManager) connect() error {
 func (m *LaunchManager) Play() error {
 	m.Lock()
 	defer m.Unlock()
+	return m.play()
+}
 
+// play starts playback, the caller must hold the lock.
+func (m *LaunchManager) play() error {
 	if m.isPlaying() {
 		return nil
 	}
+	if m.governor == nil {
+		return ErrNotSupported
+	}
+	if m.governor.Stopped() {
+		return ErrEmergencyStop
+	}
 
 	if err := m.connect(); err != nil {
 		return err
@@ -105,46 +354,135 @@ func (m *LaunchManager) Play() error {
 	if m.player != nil {
 		m.playingMux.Lock()
 		m.playing = true
+		m.paused = false
+		m.parkOnEnd = true
+		gen := m.generation
 		m.playingMux.Unlock()
+		playing.Set(1)
 		m.wg.Add(1)
-		go m.playroutine()
+		go m.playroutine(gen)
+		m.watchdog.Start()
 	}
 	return nil
 }
 
-// playroutine will send actions from the script player to the launch.
-func (m *LaunchManager) playroutine() {
+// playroutine will send actions from the script player to the device. When
+// the script ends without changes to playback (generation gen) the next
+// script in the queue is started once the device is parked.
+func (m *LaunchManager) playroutine(gen uint64) {
 	for a := range m.player.Play() {
-		m.launch.Move(a.Position, a.Speed)
-		m.tracers.Range(func(key interface{}, value interface{}) bool {
-			if t, ok := key.(chan protocol.Action); ok {
-				select {
-				case t <- a:
-				default:
-					close(t)
-					m.tracers.Delete(t)
-				}
-			}
-			return true
-		})
+		if a.IsStroke() {
+			m.governor.Move(a.Position, a.Speed)
+			m.playingMux.Lock()
+			m.position = a.Position
+			m.playingMux.Unlock()
+		} else {
+			m.moveAxis(a)
+		}
+		m.trace(a)
 	}
+	m.watchdog.Stop()
+	m.playingMux.Lock()
+	m.ended = true
+	m.playingMux.Unlock()
+	parking := m.park()
 	m.playingMux.Lock()
 	m.playing = false
+	m.paused = false
+	m.ended = false
 	m.playingMux.Unlock()
+	playing.Set(0)
 	m.wg.Done()
+	go m.advance(gen, parking)
+}
+
+// moveAxis sends moves of other axes than the stroke to devices that support
+// them, other devices ignore them. The moves are not limited by the governor
+// but are halted by the emergency stop.
+func (m *LaunchManager) moveAxis(a protocol.Action) {
+	am, ok := m.device.(AxisMover)
+	if !ok || !m.device.Capabilities().Has(MultiAxis) ||
+		m.governor.Stopped() {
+		return
+	}
+	am.MoveAxis(a.Axis, a.Position, a.Speed)
+	movesTotal.Inc()
 }
 
-// Stop will halt playback and reset the scriptplayer.
+// park moves linear devices to the park position and returns the time the
+// move takes. It does not wait for the move, callers like Stop hold the lock.
+func (m *LaunchManager) park() time.Duration {
+	m.playingMux.Lock()
+	pos, spd := m.parkPosition, m.parkSpeed
+	dist := pos - m.position
+	enabled := m.parkOnEnd
+	m.playingMux.Unlock()
+
+	if !enabled || pos == ParkOff || dist == 0 ||
+		!m.device.Capabilities().Has(Linear) || m.governor.Stopped() {
+		return 0
+	}
+	if dist < 0 {
+		dist = -dist
+	}
+	m.governor.Park(pos, spd)
+	d := funscript.Duration(dist, spd)
+	m.playingMux.Lock()
+	m.position = pos
+	m.parked = time.Now().Add(d)
+	m.playingMux.Unlock()
+	return d
+}
+
+// WaitUntilParked returns when the last park move is completed.
+func (m *LaunchManager) WaitUntilParked() {
+	m.playingMux.Lock()
+	parked := m.parked
+	m.playingMux.Unlock()
+	time.Sleep(time.Until(parked))
+}
+
+// setPaused sets if the player is paused.
+func (m *LaunchManager) setPaused(b bool) {
+	m.playingMux.Lock()
+	defer m.playingMux.Unlock()
+	m.paused = b
+}
+
+// setParkOnEnd sets if the device should park when playback ends.
+func (m *LaunchManager) setParkOnEnd(b bool) {
+	m.playingMux.Lock()
+	defer m.playingMux.Unlock()
+	m.parkOnEnd = b
+}
+
+// Stop will halt playback and reset the scriptplayer. It returns when the
+// device is parked.
 func (m *LaunchManager) Stop() error {
 	m.Lock()
 	defer m.Unlock()
 
+	m.cancelAdvance()
 	if m.isPlaying() {
-		return m.player.Stop()
+		if err := m.stopPlayer(); err != nil {
+			return err
+		}
+		m.wg.Wait()
 	}
 	return nil
 }
 
+// stopPlayer stops the player unless it already finished playing.
+func (m *LaunchManager) stopPlayer() error {
+	m.playingMux.Lock()
+	ended := m.ended
+	m.playingMux.Unlock()
+	if ended {
+		return nil
+	}
+	return countTimeout(m.player.Stop())
+}
+
 // Pause will halt playback but keep the current position.
 func (m *LaunchManager) Pause() error {
 	m.Lock()
@@ -152,7 +490,13 @@ func (m *LaunchManager) Pause() error {
 
 	if m.isPlaying() {
 		if pp, ok := m.player.(protocol.Pausable); ok {
-			return pp.Pause()
+			if err := countTimeout(pp.Pause()); err != nil {
+				return err
+			}
+			// Nothing moves, no need for heartbeats.
+			m.watchdog.Stop()
+			m.setPaused(true)
+			return nil
 		}
 		return ErrNotSupported
 	}
@@ -166,7 +510,12 @@ func (m *LaunchManager) Resume() error {
 
 	if m.isPlaying() {
 		if pp, ok := m.player.(protocol.Pausable); ok {
-			return pp.Resume()
+			if err := countTimeout(pp.Resume()); err != nil {
+				return err
+			}
+			m.watchdog.Start()
+			m.setPaused(false)
+			return nil
 		}
 		return ErrNotSupported
 	}
@@ -177,16 +526,32 @@ func (m *LaunchManager) Resume() error {
 func (m *LaunchManager) Skip(p time.Duration) error {
 	m.Lock()
 	defer m.Unlock()
+	return m.skip(p)
+}
 
+// skip jumps playback position to the specified time, the caller must hold
+// the lock.
+func (m *LaunchManager) skip(p time.Duration) error {
 	if m.isPlaying() {
 		if pp, ok := m.player.(protocol.Skippable); ok {
-			return pp.Skip(p)
+			return countTimeout(pp.Skip(p))
 		}
 		return ErrNotSupported
 	}
 	return ErrNotPlaying
 }
 
+// Tune changes the tempo and depth of the playing pattern.
+func (m *LaunchManager) Tune(tempo, depth int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if pp, ok := m.player.(protocol.Tunable); ok {
+		return pp.Tune(tempo, depth)
+	}
+	return ErrNotSupported
+}
+
 // Dump will return the full loaded script.
 func (m *LaunchManager) Dump() (protocol.TimedActions, error) {
 	m.Lock()
@@ -198,14 +563,102 @@ func (m *LaunchManager) Dump() (protocol.TimedActions, error) {
 	return nil, ErrNotSupported
 }
 
-// Trace returns a channel that receives the same actions as are send to the
-// Launch.
-func (m *LaunchManager) Trace() <-chan protocol.Action {
-	t := make(chan protocol.Action, 8)
-	m.tracers.Store(t, true)
+// Metadata returns the description of the loaded script, or nil when there is
+// none.
+func (m *LaunchManager) Metadata() *protocol.Metadata {
+	m.Lock()
+	defer m.Unlock()
+	return m.metadata()
+}
+
+// metadata returns the description of the loaded script, the caller must hold
+// the lock.
+func (m *LaunchManager) metadata() *protocol.Metadata {
+	if d, ok := m.player.(protocol.Describable); ok {
+		return d.Metadata()
+	}
+	return nil
+}
+
+// Trace returns a channel that receives the same actions (protocol.Action) as
+// are send to the device, and the safety violations (Event) of those actions.
+func (m *LaunchManager) Trace() <-chan interface{} {
+	t := make(chan interface{}, 8)
+	m.tracersMux.Lock()
+	m.tracers[t] = true
+	m.tracersMux.Unlock()
+	traceSubscribers.Inc()
 	return t
 }
 
+// CloseTrace unsubscribes and closes the trace channel t returned by Trace.
+func (m *LaunchManager) CloseTrace(t <-chan interface{}) {
+	m.tracersMux.Lock()
+	defer m.tracersMux.Unlock()
+	for c := range m.tracers {
+		if (<-chan interface{})(c) == t {
+			close(c)
+			delete(m.tracers, c)
+			traceSubscribers.Dec()
+		}
+	}
+}
+
+// trace sends v to all trace subscribers. Subscribers that are not keeping up
+// are closed.
+func (m *LaunchManager) trace(v interface{}) {
+	m.tracersMux.Lock()
+	defer m.tracersMux.Unlock()
+	for t := range m.tracers {
+		select {
+		case t <- v:
+		default:
+			close(t)
+			delete(m.tracers, t)
+			traceDroppedTotal.Inc()
+			traceSubscribers.Dec()
+		}
+	}
+}
+
+// Events returns a channel that receives events about the device, like
+// emergency stops and the watchdog halting playback.
+func (m *LaunchManager) Events() <-chan Event {
+	e := make(chan Event, 8)
+	m.listenersMux.Lock()
+	m.listeners[e] = true
+	m.listenersMux.Unlock()
+	return e
+}
+
+// CloseEvents unsubscribes and closes the event channel e returned by Events.
+func (m *LaunchManager) CloseEvents(e <-chan Event) {
+	m.listenersMux.Lock()
+	defer m.listenersMux.Unlock()
+	for l := range m.listeners {
+		if (<-chan Event)(l) == e {
+			close(l)
+			delete(m.listeners, l)
+		}
+	}
+}
+
+// publish sends e to all event listeners. Listeners that are not keeping up
+// are closed.
+func (m *LaunchManager) publish(e Event) {
+	m.listenersMux.Lock()
+	defer m.listenersMux.Unlock()
+	for l := range m.listeners {
+		select {
+		case l <- e:
+		default:
+			close(l)
+			delete(m.listeners, l)
+			eventsDroppedTotal.Inc()
+		}
+	}
+}
+
 // isPlaying returns true if the loaded scriptplayer is playing.
 func (m *LaunchManager) isPlaying() bool {
 	m.playingMux.Lock()
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
 )
 
 var testScript = []protocol.TimedAction{
@@ -46,6 +47,8 @@ type fakeLaunch struct {
 	DisFunc func()
 
 	MoveCount       int
+	LastPosition    int
+	LastSpeed       int
 	ConnectCount    int
 	DisconnectCount int
 }
@@ -54,6 +57,8 @@ func (f *fakeLaunch) Move(position, speed int) {
 	f.Lock()
 	defer f.Unlock()
 	f.MoveCount++
+	f.LastPosition = position
+	f.LastSpeed = speed
 }
 func (f *fakeLaunch) Connect(ctx context.Context) error {
 	f.Lock()
@@ -75,7 +80,7 @@ func (f *fakeLaunch) HandleDisconnect(fnc func()) {
 // TestManager is a basic test running through most of managers functions.
 func TestManager(t *testing.T) {
 	fake := &fakeLaunch{}
-	lm := NewLaunchManager(fake)
+	lm := NewLaunchManager(NewLaunchDevice(fake))
 	p := protocol.NewTimedActionsPlayer()
 	p.Script = testScript
 	lm.SetScriptPlayer(p)
@@ -122,7 +127,7 @@ func TestManager(t *testing.T) {
 
 func TestTrace(t *testing.T) {
 	fake := &fakeLaunch{}
-	lm := NewLaunchManager(fake)
+	lm := NewLaunchManager(NewLaunchDevice(fake))
 	p := protocol.NewTimedActionsPlayer()
 	p.Script = testScript
 	lm.SetScriptPlayer(p)
@@ -135,7 +140,7 @@ func TestTrace(t *testing.T) {
 	breakAtAction := 2
 	breakTimer := time.After(testScript[breakAtAction-1].Time +
 		time.Millisecond*25)
-	traced := make([]protocol.Action, 0, len(testScript))
+	traced := make([]interface{}, 0, len(testScript))
 STOP:
 	for {
 		select {
@@ -154,9 +159,31 @@ STOP:
 	}
 }
 
+func TestCloseTrace(t *testing.T) {
+	lm := NewLaunchManager(NewLaunchDevice(&fakeLaunch{}))
+	before := traceSubscribers.Value()
+	trace := lm.Trace()
+	if v := traceSubscribers.Value(); v != before+1 {
+		t.Errorf("subscribers after Trace, want %v, got %v", before+1, v)
+	}
+	dropped := traceDroppedTotal.Value()
+	lm.CloseTrace(trace)
+	if _, ok := <-trace; ok {
+		t.Errorf("trace channel not closed")
+	}
+	if v := traceSubscribers.Value(); v != before {
+		t.Errorf("subscribers after CloseTrace, want %v, got %v", before, v)
+	}
+	if v := traceDroppedTotal.Value(); v != dropped {
+		t.Errorf("closed trace counted as dropped")
+	}
+	// Closing twice, or after being dropped, is a no-op
+	lm.CloseTrace(trace)
+}
+
 func TestStop(t *testing.T) {
 	fake := &fakeLaunch{}
-	lm := NewLaunchManager(fake)
+	lm := NewLaunchManager(NewLaunchDevice(fake))
 	if err := lm.Stop(); err != nil {
 		t.Errorf("stop on empty player did return an error")
 	}
@@ -182,7 +209,7 @@ func TestStop(t *testing.T) {
 
 func TestDump(t *testing.T) {
 	fake := &fakeLaunch{}
-	lm := NewLaunchManager(fake)
+	lm := NewLaunchManager(NewLaunchDevice(fake))
 
 	if _, err := lm.Dump(); err != ErrNotSupported {
 		t.Errorf("dump on empty player did not return error")
@@ -203,3 +230,227 @@ func TestDump(t *testing.T) {
 	}
 
 }
+
+type fakeVibrator struct {
+	fakeLaunch
+
+	Intensities []float64
+}
+
+func (f *fakeVibrator) Capabilities() Capability {
+	return Vibrate
+}
+func (f *fakeVibrator) Vibrate(intensity float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.Intensities = append(f.Intensities, intensity)
+}
+
+func TestCapabilities(t *testing.T) {
+	if s := (Linear | Rotate).String(); s != "linear,rotate" {
+		t.Errorf("unexpected capability string: %s", s)
+	}
+	if !(Linear | Vibrate).Has(Vibrate) || Linear.Has(Linear|Vibrate) {
+		t.Errorf("has returned wrong result")
+	}
+}
+
+// TestVibrateDispatch tests if moves are converted to vibrations for devices
+// that only vibrate.
+func TestVibrateDispatch(t *testing.T) {
+	fake := &fakeVibrator{}
+	lm := NewLaunchManager(fake)
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	lm.SetScriptPlayer(p)
+
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	lm.WaitUntilStopped(done)
+	<-done
+
+	fake.Lock()
+	defer fake.Unlock()
+	if fake.MoveCount != 0 {
+		t.Errorf("vibrator received moves")
+	}
+	if len(fake.Intensities) != len(testScript) {
+		t.Fatalf("expected %d vibrations, got %d", len(testScript),
+			len(fake.Intensities))
+	}
+	for i, a := range testScript {
+		var from int
+		if i > 0 {
+			from = testScript[i-1].Position
+		}
+		want := DefaultVibrationCurve.Intensity(a.Position-from, a.Speed)
+		if fake.Intensities[i] != want {
+			t.Errorf("vibration %d: want %.2f, got %.2f", i, want,
+				fake.Intensities[i])
+		}
+	}
+}
+
+// fakeMultiAxis is a linear device that records the moves of other axes.
+type fakeMultiAxis struct {
+	fakeLaunch
+	AxisMoves []protocol.Action
+}
+
+func (f *fakeMultiAxis) Capabilities() Capability {
+	return Linear | MultiAxis
+}
+func (f *fakeMultiAxis) MoveAxis(axis string, position, speed int) {
+	f.Lock()
+	defer f.Unlock()
+	f.AxisMoves = append(f.AxisMoves, protocol.Action{
+		Position: position,
+		Speed:    speed,
+		Axis:     axis,
+	})
+}
+
+func TestMultiAxisDispatch(t *testing.T) {
+	script := make([]protocol.TimedAction, 0, len(testScript)*2)
+	for _, a := range testScript {
+		script = append(script, a)
+		a.Axis = protocol.AxisRoll
+		script = append(script, a)
+	}
+
+	multi := &fakeMultiAxis{}
+	single := &fakeLaunch{}
+	for _, d := range []Device{multi, NewLaunchDevice(single)} {
+		lm := NewLaunchManager(d)
+		p := protocol.NewTimedActionsPlayer()
+		p.Script = script
+		lm.SetScriptPlayer(p)
+		if err := lm.Play(); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		lm.WaitUntilStopped(done)
+		<-done
+	}
+
+	multi.Lock()
+	defer multi.Unlock()
+	if multi.MoveCount != len(testScript) {
+		t.Errorf("expected %d stroke moves, got %d", len(testScript),
+			multi.MoveCount)
+	}
+	if len(multi.AxisMoves) != len(testScript) {
+		t.Fatalf("expected %d axis moves, got %d", len(testScript),
+			len(multi.AxisMoves))
+	}
+	for i, a := range multi.AxisMoves {
+		if a.Axis != protocol.AxisRoll ||
+			a.Position != testScript[i].Position {
+			t.Errorf("axis move %d: unexpected %v", i, a)
+		}
+	}
+	single.Lock()
+	defer single.Unlock()
+	if single.MoveCount != len(testScript) {
+		t.Errorf("single axis device: expected %d moves, got %d",
+			len(testScript), single.MoveCount)
+	}
+}
+
+func TestPlayUnsupportedDevice(t *testing.T) {
+	lm := NewLaunchManager(&fakeRotatorless{})
+	lm.SetScriptPlayer(protocol.NewTimedActionsPlayer())
+	if err := lm.Play(); err != ErrNotSupported {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+// fakeRotatorless announces Rotate but does not implement Rotator.
+type fakeRotatorless struct {
+	fakeLaunch
+}
+
+func (f *fakeRotatorless) Capabilities() Capability {
+	return Rotate
+}
+
+func TestPark(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	lm.SetPark(ParkTop, 99)
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	lm.SetScriptPlayer(p)
+
+	// Park on script end
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	lm.WaitUntilStopped(done)
+	<-done
+	fake.Lock()
+	if fake.MoveCount != len(testScript)+1 {
+		t.Errorf("expected %d moves, got %d", len(testScript)+1,
+			fake.MoveCount)
+	}
+	if fake.LastPosition != ParkTop || fake.LastSpeed != 99 {
+		t.Errorf("device not parked: position %d, speed %d",
+			fake.LastPosition, fake.LastSpeed)
+	}
+	fake.MoveCount = 0
+	fake.Unlock()
+
+	// Park on stop
+	lm.SetPark(ParkBottom, 0)
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(testScript[0].Time + time.Millisecond*25)
+	if err := lm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Lock()
+	defer fake.Unlock()
+	if fake.MoveCount != 2 {
+		t.Errorf("expected 2 moves, got %d", fake.MoveCount)
+	}
+	if fake.LastPosition != ParkBottom ||
+		fake.LastSpeed != DefaultParkSpeed {
+		t.Errorf("device not parked: position %d, speed %d",
+			fake.LastPosition, fake.LastSpeed)
+	}
+}
+
+func TestStopSlowPark(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	// Parking from 5 takes almost a second
+	lm.SetPark(ParkTop, funscript.SpeedLimitMin)
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	lm.SetScriptPlayer(p)
+
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(testScript[0].Time + time.Millisecond*25)
+	start := time.Now()
+	if err := lm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Millisecond*250 {
+		t.Errorf("stop waited %s for the park move", d)
+	}
+	fake.Lock()
+	defer fake.Unlock()
+	if fake.LastPosition != ParkTop {
+		t.Errorf("device not parked: position %d", fake.LastPosition)
+	}
+	lm.WaitUntilParked()
+	if d := time.Since(start); d < time.Millisecond*500 {
+		t.Errorf("wait until parked returned after %s", d)
+	}
+}
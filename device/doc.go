@@ -2,5 +2,10 @@
 Package device implements device managers.
 
 Device managers handles the communication with devices (like the Launch.)
+
+Output devices implement the Device interface and announce their
+capabilities. The LaunchManager sends the moves of a script directly to
+devices with the Linear capability, for devices that can only vibrate or
+rotate the moves are converted into an intensity using a VibrationCurve.
 */
 package device
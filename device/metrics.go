@@ -0,0 +1,42 @@
+package device
+
+import (
+	"github.com/funjack/launchcontrol/metrics"
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+var (
+	movesTotal = metrics.NewCounter("launchcontrol_moves_total",
+		"Number of move commands sent to the device.")
+	traceSubscribers = metrics.NewGauge("launchcontrol_trace_subscribers",
+		"Number of active trace subscribers.")
+	traceDroppedTotal = metrics.NewCounter("launchcontrol_trace_dropped_total",
+		"Number of trace events dropped because a subscriber was too slow.")
+	connectAttemptsTotal = metrics.NewCounter("launchcontrol_connect_attempts_total",
+		"Number of attempts to connect to the device.")
+	connectFailuresTotal = metrics.NewCounter("launchcontrol_connect_failures_total",
+		"Number of failed attempts to connect to the device.")
+	disconnectsTotal = metrics.NewCounter("launchcontrol_disconnects_total",
+		"Number of times the device disconnected.")
+	reconnectsTotal = metrics.NewCounter("launchcontrol_reconnects_total",
+		"Number of successful connects after the device disconnected.")
+	playing = metrics.NewGauge("launchcontrol_playing",
+		"Whether a script is playing (1) or not (0).")
+	commandTimeoutsTotal = metrics.NewCounter("launchcontrol_command_timeouts_total",
+		"Number of player commands that timed out.")
+	safetyViolationsTotal = metrics.NewCounter("launchcontrol_safety_violations_total",
+		"Number of moves that violated the safety limits.")
+	watchdogExpiredTotal = metrics.NewCounter("launchcontrol_watchdog_expired_total",
+		"Number of times playback was halted because heartbeats stopped.")
+	eventsDroppedTotal = metrics.NewCounter("launchcontrol_events_dropped_total",
+		"Number of events dropped because a listener was too slow.")
+)
+
+// countTimeout increments the command timeout counter when err is a player
+// timeout and returns err.
+func countTimeout(err error) error {
+	if err == protocol.ErrTimeout {
+		commandTimeoutsTotal.Inc()
+	}
+	return err
+}
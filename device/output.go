@@ -0,0 +1,103 @@
+package device
+
+import (
+	"context"
+	"strings"
+
+	"github.com/funjack/golaunch"
+)
+
+// Capability is a set of features supported by a Device.
+type Capability uint
+
+// Capabilities a Device can have.
+const (
+	// Linear devices move to a position (LinearDevice.)
+	Linear Capability = 1 << iota
+	// Vibrate devices vibrate with an intensity (Vibrator.)
+	Vibrate
+	// Rotate devices rotate with an intensity (Rotator.)
+	Rotate
+	// PositionFeedback devices report their actual position
+	// (PositionReporter.)
+	PositionFeedback
+	// MultiAxis devices move other axes than the stroke (AxisMover.)
+	MultiAxis
+)
+
+var capabilityNames = []string{"linear", "vibrate", "rotate", "position",
+	"multiaxis"}
+
+// Has returns true if all capabilities in o are present in c.
+func (c Capability) Has(o Capability) bool {
+	return c&o == o
+}
+
+// String returns a comma separated list of capability names.
+func (c Capability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c.Has(1 << uint(i)) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// Device is an output device managed by the LaunchManager. Besides
+// connection handling, a Device implements the interface belonging to each of
+// its capabilities.
+type Device interface {
+	Connect(ctx context.Context) error
+	Disconnect()
+	HandleDisconnect(func())
+	Capabilities() Capability
+}
+
+// LinearDevice is a device that moves to a position (0-99) with a Launch
+// speed (20-99.)
+type LinearDevice interface {
+	Move(position, speed int)
+}
+
+// Vibrator is a device that vibrates with an intensity (0.0-1.0.)
+type Vibrator interface {
+	Vibrate(intensity float64)
+}
+
+// Rotator is a device that rotates with an intensity (0.0-1.0) in the given
+// direction.
+type Rotator interface {
+	Rotate(intensity float64, clockwise bool)
+}
+
+// AxisMover is a device that moves other axes than the stroke, named like the
+// T-Code axes (eg R0, R1 or R2), to a position (0-99) with a Launch speed
+// (20-99.)
+type AxisMover interface {
+	MoveAxis(axis string, position, speed int)
+}
+
+// PositionReporter is a device that reports its actual position (0-99.)
+type PositionReporter interface {
+	Position() int
+}
+
+// launchDevice adapts a golaunch.Launch to a Device.
+type launchDevice struct {
+	golaunch.Launch
+}
+
+// NewLaunchDevice returns a linear Device for a golaunch.Launch (or anything
+// else that behaves like one.)
+func NewLaunchDevice(l golaunch.Launch) Device {
+	return launchDevice{l}
+}
+
+// Capabilities implements Device.
+func (launchDevice) Capabilities() Capability {
+	return Linear
+}
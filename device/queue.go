@@ -0,0 +1,162 @@
+package device
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// ErrQueueEnd is returned when there is no next or previous script in the
+// queue.
+var ErrQueueEnd = errors.New("no more scripts in queue")
+
+// queueItem is a player in the queue.
+type queueItem struct {
+	player protocol.Player
+	gap    time.Duration // wait before playing
+}
+
+// QueueItem describes a script in the queue.
+type QueueItem struct {
+	Gap string `json:"gap"`
+}
+
+// QueueStatus describes the queue.
+type QueueStatus struct {
+	Current int         `json:"current"`
+	Items   []QueueItem `json:"items"`
+}
+
+// Enqueue adds a player to the end of the queue. When the script before it
+// ends, it starts playing after waiting for gap. If the queue was empty the
+// player is loaded but not started.
+func (m *LaunchManager) Enqueue(p protocol.Player, gap time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.queue = append(m.queue, queueItem{
+		player: p,
+		gap:    gap,
+	})
+	if len(m.queue) == 1 {
+		m.current = 0
+		m.player = p
+	}
+}
+
+// Next switches to the next script in the queue. If a script is playing
+// the next one starts playing right away.
+func (m *LaunchManager) Next() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.current+1 >= len(m.queue) {
+		return ErrQueueEnd
+	}
+	return m.jump(m.current + 1)
+}
+
+// Previous switches to the previous script in the queue. If a script is
+// playing the previous one starts playing right away.
+func (m *LaunchManager) Previous() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.current <= 0 || m.current >= len(m.queue) {
+		return ErrQueueEnd
+	}
+	return m.jump(m.current - 1)
+}
+
+// ClearQueue removes all scripts after the current one from the queue.
+func (m *LaunchManager) ClearQueue() {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.current < len(m.queue) {
+		m.queue = m.queue[:m.current+1]
+	}
+}
+
+// Queue returns the scripts in the queue.
+func (m *LaunchManager) Queue() QueueStatus {
+	m.Lock()
+	defer m.Unlock()
+
+	s := QueueStatus{
+		Current: m.current,
+		Items:   make([]QueueItem, len(m.queue)),
+	}
+	for i, item := range m.queue {
+		s.Items[i] = QueueItem{
+			Gap: item.gap.String(),
+		}
+	}
+	return s
+}
+
+// jump switches to queue item i, and continues playing if a script was
+// playing.
+func (m *LaunchManager) jump(i int) error {
+	wasPlaying := m.isPlaying()
+	if wasPlaying {
+		// The new script moves the device, no need to park first.
+		m.cancelAdvance()
+		m.setParkOnEnd(false)
+		if err := m.stopPlayer(); err != nil {
+			return err
+		}
+		m.wg.Wait()
+	}
+	m.cancelAdvance()
+	m.current = i
+	m.player = m.queue[i].player
+	if wasPlaying {
+		return m.play()
+	}
+	return nil
+}
+
+// advance starts the next script in the queue after parking (the time the
+// park move takes) and its gap, unless playback was changed (generation gen)
+// in the meantime.
+func (m *LaunchManager) advance(gen uint64, parking time.Duration) {
+	m.Lock()
+	if !m.sameGeneration(gen) || m.current+1 >= len(m.queue) {
+		m.Unlock()
+		return
+	}
+	gap := m.queue[m.current+1].gap
+	m.Unlock()
+
+	time.Sleep(parking + gap)
+
+	m.Lock()
+	defer m.Unlock()
+	if !m.sameGeneration(gen) || m.isPlaying() ||
+		m.current+1 >= len(m.queue) {
+		return
+	}
+	m.current++
+	m.player = m.queue[m.current].player
+	if err := m.play(); err != nil {
+		log.Printf("Error playing next script in queue: %v", err)
+	}
+}
+
+// cancelAdvance prevents a pending advance to the next script in the queue.
+func (m *LaunchManager) cancelAdvance() {
+	m.playingMux.Lock()
+	defer m.playingMux.Unlock()
+	m.generation++
+}
+
+// sameGeneration returns true if no playback changes were made since
+// generation gen.
+func (m *LaunchManager) sameGeneration(gen uint64) bool {
+	m.playingMux.Lock()
+	defer m.playingMux.Unlock()
+	return m.generation == gen
+}
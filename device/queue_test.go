@@ -0,0 +1,81 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func newTestPlayer() *protocol.TimedActionsPlayer {
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	return p
+}
+
+func TestQueue(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	if err := lm.Next(); err != ErrQueueEnd {
+		t.Errorf("next on empty queue: want ErrQueueEnd, got %v", err)
+	}
+
+	lm.Enqueue(newTestPlayer(), 0)
+	lm.Enqueue(newTestPlayer(), time.Millisecond*50)
+	if q := lm.Queue(); len(q.Items) != 2 || q.Current != 0 {
+		t.Fatalf("unexpected queue: %+v", q)
+	}
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both scripts and the gap
+	time.Sleep(2*testScript[len(testScript)-1].Time + time.Millisecond*200)
+	if q := lm.Queue(); q.Current != 1 {
+		t.Errorf("queue did not advance: %+v", q)
+	}
+	fake.Lock()
+	if fake.MoveCount != 2*len(testScript) {
+		t.Errorf("expected %d moves, got %d", 2*len(testScript),
+			fake.MoveCount)
+	}
+	fake.Unlock()
+	if err := lm.Next(); err != ErrQueueEnd {
+		t.Errorf("next at end of queue: want ErrQueueEnd, got %v", err)
+	}
+
+	if err := lm.Previous(); err != nil {
+		t.Fatal(err)
+	}
+	if q := lm.Queue(); q.Current != 0 {
+		t.Errorf("previous did not go back: %+v", q)
+	}
+	if err := lm.Previous(); err != ErrQueueEnd {
+		t.Errorf("previous at start: want ErrQueueEnd, got %v", err)
+	}
+	lm.ClearQueue()
+	if q := lm.Queue(); len(q.Items) != 1 {
+		t.Errorf("queue not cleared: %+v", q)
+	}
+}
+
+func TestQueueStop(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	lm.Enqueue(newTestPlayer(), 0)
+	lm.Enqueue(newTestPlayer(), 0)
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(testScript[0].Time + time.Millisecond*25)
+	if err := lm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if s := lm.Status(); s.Playing {
+		t.Errorf("queue advanced after stop")
+	}
+	if q := lm.Queue(); q.Current != 0 {
+		t.Errorf("queue advanced after stop: %+v", q)
+	}
+}
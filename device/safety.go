@@ -0,0 +1,270 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// ErrEmergencyStop is returned when playback is refused because the
+// emergency stop is engaged.
+var ErrEmergencyStop = errors.New("emergency stop engaged")
+
+// Event types published to event listeners.
+const (
+	// EventSafety is send to trace subscribers when a move violated the
+	// SafetyLimits.
+	EventSafety = "safety"
+	// EventEmergencyStop is published when the emergency stop is engaged
+	// or cleared.
+	EventEmergencyStop = "estop"
+)
+
+// Event is a notification about the state of the device.
+type Event struct {
+	Type    string    `json:"event"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// DefaultSafetyLimits are the limits enforced when none are configured.
+var DefaultSafetyLimits = SafetyLimits{
+	MinInterval:       time.Millisecond * 25,
+	Window:            time.Second * 3,
+	MaxSustainedSpeed: 90,
+	PositionMin:       0,
+	PositionMax:       99,
+}
+
+// SafetyLimits are the limits enforced by the Governor on every move.
+type SafetyLimits struct {
+	// MinInterval is the shortest time between two moves, moves that
+	// follow sooner are dropped.
+	MinInterval time.Duration
+	// Window is the period over which the sustained speed is measured.
+	Window time.Duration
+	// MaxSustainedSpeed is the highest average speed allowed over Window,
+	// faster moves are slowed down.
+	MaxSustainedSpeed int
+	// PositionMin and PositionMax are the bounds positions are limited
+	// to.
+	PositionMin, PositionMax int
+}
+
+// timedSpeed is the speed of a move at the time it was send.
+type timedSpeed struct {
+	Time     time.Time
+	Position int
+	Speed    int
+}
+
+// Governor is a LinearDevice that enforces SafetyLimits on all moves before
+// passing them on, and drops all moves while the emergency stop is engaged.
+type Governor struct {
+	sync.Mutex
+
+	mover   LinearDevice
+	limits  SafetyLimits
+	report  func(Event)
+	now     func() time.Time
+	last    time.Time    // time of the last move
+	history []timedSpeed // moves within the window
+	estop   bool
+}
+
+// NewGovernor returns a governor passing moves to m. Violations are logged
+// and passed to report (when not nil.)
+func NewGovernor(m LinearDevice, l SafetyLimits, report func(Event)) *Governor {
+	return &Governor{
+		mover:  m,
+		limits: l,
+		report: report,
+		now:    time.Now,
+	}
+}
+
+// SetLimits changes the enforced limits.
+func (g *Governor) SetLimits(l SafetyLimits) {
+	g.Lock()
+	defer g.Unlock()
+	g.limits = l
+	g.history = nil
+}
+
+// Move implements LinearDevice.
+func (g *Governor) Move(position, speed int) {
+	g.Lock()
+	if g.estop {
+		g.Unlock()
+		return
+	}
+	l := g.limits
+	now := g.now()
+	if !g.last.IsZero() && now.Sub(g.last) < l.MinInterval {
+		g.Unlock()
+		g.violation("move dropped, %s since previous move is below %s",
+			now.Sub(g.last), l.MinInterval)
+		return
+	}
+	// The new move is expected to last as long as the previous one
+	interval := l.Window
+	if !g.last.IsZero() && now.Sub(g.last) < interval {
+		interval = now.Sub(g.last)
+	}
+	g.last = now
+
+	var violations []string
+	if l.PositionMin < l.PositionMax {
+		if position < l.PositionMin || position > l.PositionMax {
+			violations = append(violations, fmt.Sprintf(
+				"position %d outside %d-%d", position,
+				l.PositionMin, l.PositionMax))
+			if position < l.PositionMin {
+				position = l.PositionMin
+			} else {
+				position = l.PositionMax
+			}
+		}
+	}
+	if l.MaxSustainedSpeed > 0 && l.Window > 0 {
+		if max := g.allowedSpeed(now, interval); speed > max {
+			violations = append(violations, fmt.Sprintf(
+				"speed %d slowed to %d, sustained speed above %d",
+				speed, max, l.MaxSustainedSpeed))
+			speed = max
+		}
+		g.history = append(g.history, timedSpeed{now, position, speed})
+	}
+	g.mover.Move(position, speed)
+	movesTotal.Inc()
+	g.Unlock()
+
+	for _, v := range violations {
+		g.violation("%s", v)
+	}
+}
+
+// Park moves to position with speed without checking the move rate and
+// sustained speed, it is used to slowly move to a safe position after
+// playback. The position bounds and emergency stop still apply.
+func (g *Governor) Park(position, speed int) {
+	g.Lock()
+	defer g.Unlock()
+	if g.estop {
+		return
+	}
+	l := g.limits
+	if l.PositionMin < l.PositionMax {
+		if position < l.PositionMin {
+			position = l.PositionMin
+		} else if position > l.PositionMax {
+			position = l.PositionMax
+		}
+	}
+	g.last = g.now()
+	g.mover.Move(position, speed)
+	movesTotal.Inc()
+}
+
+// allowedSpeed returns the fastest speed a move at now, lasting interval, can
+// have while keeping the average speed over the window within the limit.
+//
+// The average is weighted by time: every move counts for the time it takes
+// to reach its position, or until the next move when that is sooner. The
+// time the device stands still counts as speed 0, the time before the first
+// move in the history does not count. Moves that ended before the window are
+// dropped from the history.
+func (g *Governor) allowedSpeed(now time.Time, interval time.Duration) int {
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	// Keep the last move that started before the window, it can still
+	// be moving at the start of the window.
+	var i int
+	for i+1 < len(g.history) &&
+		g.history[i+1].Time.Before(now.Add(-g.limits.Window)) {
+		i++
+	}
+	g.history = g.history[i:]
+
+	start := now.Add(interval - g.limits.Window)
+	period := interval // time covered by the average
+	var moved float64  // sum of speed times duration of the moves
+	for j, s := range g.history {
+		end := now
+		if j+1 < len(g.history) {
+			end = g.history[j+1].Time
+		}
+		dist := 99 // previous position unknown, assume a full stroke
+		if j > 0 {
+			dist = s.Position - g.history[j-1].Position
+			if dist < 0 {
+				dist = -dist
+			}
+		}
+		if arrive := s.Time.Add(funscript.Duration(dist,
+			s.Speed)); arrive.Before(end) {
+			end = arrive
+		}
+		from := s.Time
+		if from.Before(start) {
+			from = start
+		}
+		if j == 0 {
+			period += now.Sub(from)
+		}
+		if end.After(from) {
+			moved += float64(s.Speed) * end.Sub(from).Seconds()
+		}
+	}
+
+	allowed := int((float64(g.limits.MaxSustainedSpeed)*period.Seconds() -
+		moved) / interval.Seconds())
+	// Slow commands crash the Launch
+	if allowed < funscript.SpeedLimitMin {
+		allowed = funscript.SpeedLimitMin
+	}
+	return allowed
+}
+
+// violation logs and reports a safety violation.
+func (g *Governor) violation(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	log.Printf("Safety: %s", msg)
+	safetyViolationsTotal.Inc()
+	if g.report != nil {
+		g.report(Event{
+			Type:    EventSafety,
+			Message: msg,
+			Time:    time.Now(),
+		})
+	}
+}
+
+// EmergencyStop latches the emergency stop, all moves are dropped until
+// Clear is called.
+func (g *Governor) EmergencyStop() {
+	g.Lock()
+	defer g.Unlock()
+	g.estop = true
+}
+
+// Clear releases the emergency stop.
+func (g *Governor) Clear() {
+	g.Lock()
+	defer g.Unlock()
+	g.estop = false
+	g.last = time.Time{}
+	g.history = nil
+}
+
+// Stopped returns true if the emergency stop is engaged.
+func (g *Governor) Stopped() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.estop
+}
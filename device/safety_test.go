@@ -0,0 +1,201 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// recordMover records all moves.
+type recordMover struct {
+	Moves []protocol.Action
+}
+
+func (r *recordMover) Move(position, speed int) {
+	r.Moves = append(r.Moves, protocol.Action{
+		Position: position,
+		Speed:    speed,
+	})
+}
+
+func TestGovernor(t *testing.T) {
+	var (
+		rec    = &recordMover{}
+		events []Event
+		now    = time.Unix(0, 0)
+	)
+	g := NewGovernor(rec, SafetyLimits{
+		MinInterval:       time.Millisecond * 50,
+		Window:            time.Second,
+		MaxSustainedSpeed: 80,
+		PositionMin:       10,
+		PositionMax:       90,
+	}, func(e Event) {
+		events = append(events, e)
+	})
+	g.now = func() time.Time { return now }
+
+	// Hammering with full speed moves every 10ms
+	for i := 0; i < 100; i++ {
+		g.Move(i%2*99, 99)
+		now = now.Add(time.Millisecond * 10)
+	}
+	if len(rec.Moves) != 20 {
+		t.Errorf("expected 20 moves, got %d", len(rec.Moves))
+	}
+	for i, a := range rec.Moves {
+		if a.Position < 10 || a.Position > 90 {
+			t.Errorf("move %d: position %d out of bounds", i,
+				a.Position)
+		}
+		if a.Speed > 80 {
+			t.Errorf("move %d: speed %d above limit", i, a.Speed)
+		}
+	}
+	if len(events) == 0 || events[0].Type != EventSafety {
+		t.Errorf("violations were not reported")
+	}
+
+	// Slow moves within limits pass unchanged
+	now = now.Add(time.Second * 2)
+	events = events[:0]
+	rec.Moves = rec.Moves[:0]
+	for i := 0; i < 5; i++ {
+		g.Move(20+i*10, 50)
+		now = now.Add(time.Millisecond * 100)
+	}
+	if len(rec.Moves) != 5 || len(events) != 0 {
+		t.Errorf("moves within limits were changed: %v %v",
+			rec.Moves, events)
+	}
+	if rec.Moves[4] != (protocol.Action{Position: 60, Speed: 50}) {
+		t.Errorf("unexpected move: %v", rec.Moves[4])
+	}
+}
+
+func TestGovernorSustainedSpeed(t *testing.T) {
+	var (
+		rec = &recordMover{}
+		now = time.Unix(0, 0)
+	)
+	g := NewGovernor(rec, SafetyLimits{
+		MinInterval:       time.Millisecond * 10,
+		Window:            time.Second,
+		MaxSustainedSpeed: 50,
+	}, nil)
+	g.now = func() time.Time { return now }
+
+	// A stroke takes about 400ms at speed 50, the device stands still
+	// for the rest of the window.
+	g.Move(0, 50)
+	now = now.Add(time.Millisecond * 100)
+	g.Move(99, 50)
+	now = now.Add(time.Millisecond * 800)
+	g.Move(0, 60)
+	if len(rec.Moves) != 3 || rec.Moves[2].Speed != 60 {
+		t.Errorf("move after standing still was slowed: %v", rec.Moves)
+	}
+
+	// Strokes that follow each other before arriving keep moving all the
+	// time, each counts for 100ms.
+	now = now.Add(time.Second * 2)
+	rec.Moves = rec.Moves[:0]
+	for i := 0; i < 30; i++ {
+		g.Move(i%2*99, 80)
+		now = now.Add(time.Millisecond * 100)
+	}
+	if rec.Moves[1].Speed != 80 {
+		t.Errorf("stroke after standing still was slowed: %v",
+			rec.Moves[1])
+	}
+	var sum int
+	for _, a := range rec.Moves[20:] {
+		sum += a.Speed
+	}
+	if avg := sum / 10; avg > 50 {
+		t.Errorf("sustained speed %d above limit: %v", avg, rec.Moves)
+	}
+}
+
+func TestGovernorEmergencyStop(t *testing.T) {
+	rec := &recordMover{}
+	g := NewGovernor(rec, DefaultSafetyLimits, nil)
+	g.EmergencyStop()
+	g.Move(50, 50)
+	g.Park(0, 20)
+	if !g.Stopped() || len(rec.Moves) != 0 {
+		t.Errorf("moves passed while emergency stop is engaged")
+	}
+	g.Clear()
+	g.Move(50, 50)
+	if g.Stopped() || len(rec.Moves) != 1 {
+		t.Errorf("moves dropped after emergency stop is cleared")
+	}
+}
+
+func TestManagerEmergencyStop(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	events := lm.Events()
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	lm.SetScriptPlayer(p)
+
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lm.EmergencyStop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if e.Type != EventEmergencyStop {
+			t.Errorf("unexpected event: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no emergency stop event")
+	}
+	if err := lm.Play(); err != ErrEmergencyStop {
+		t.Errorf("expected ErrEmergencyStop, got %v", err)
+	}
+	lm.ClearEmergencyStop()
+	if lm.EmergencyStopped() {
+		t.Errorf("emergency stop not cleared")
+	}
+	if err := lm.Play(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerSafetyTrace(t *testing.T) {
+	lm := NewLaunchManager(NewLaunchDevice(&fakeLaunch{}))
+	l := DefaultSafetyLimits
+	l.PositionMin = 10
+	lm.SetSafetyLimits(l)
+	events := lm.Events()
+	trace := lm.Trace()
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = testScript
+	lm.SetScriptPlayer(p)
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-trace:
+		if e, ok := v.(Event); !ok || e.Type != EventSafety {
+			t.Errorf("expected safety violation before move, got %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no safety violation traced")
+	}
+	if a, ok := (<-trace).(protocol.Action); !ok || a.Position != 5 {
+		t.Errorf("expected traced move to 5, got %v", a)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("safety violation also send as event: %v", e)
+	default:
+	}
+	lm.Stop()
+}
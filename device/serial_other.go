@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package device
+
+import "os"
+
+// openSerial opens the serial port at path. The port is used with the speed
+// and mode configured by the OS (eg with stty or the device manager.)
+func openSerial(path string, baud int) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
@@ -0,0 +1,169 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// DefaultBaudRate is the serial speed used by OSR2/SR6 firmwares.
+const DefaultBaudRate = 115200
+
+// ErrNotConnected is returned when a command is send to a device that is not
+// connected.
+var ErrNotConnected = errors.New("not connected")
+
+// TCode controls strokers speaking T-Code over a serial port (like the OSR2
+// and SR6.) It is a Device with the Linear and MultiAxis capabilities.
+//
+// Moves are send as L0 commands with the interval in which the move should
+// complete. The interval is derived from the Launch speed and the distance to
+// travel, so scripts move the same as they would on a Launch. Moves of other
+// axes are send the same way using the axis name.
+type TCode struct {
+	sync.Mutex
+
+	path     string
+	baud     int
+	port     io.ReadWriteCloser
+	position int            // last position moved to
+	axes     map[string]int // last positions of the other axes
+
+	disconnectFunc func()
+}
+
+// NewTCode returns a T-Code device using the serial port at path.
+func NewTCode(path string, baud int) *TCode {
+	if baud <= 0 {
+		baud = DefaultBaudRate
+	}
+	return &TCode{
+		path: path,
+		baud: baud,
+		axes: make(map[string]int),
+	}
+}
+
+// Connect opens the serial port.
+func (t *TCode) Connect(ctx context.Context) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.port != nil {
+		return nil
+	}
+	port, err := openSerial(t.path, t.baud)
+	if err != nil {
+		return err
+	}
+	t.port = port
+	log.Printf("T-Code device connected on %s", t.path)
+	return nil
+}
+
+// Disconnect closes the serial port.
+func (t *TCode) Disconnect() {
+	t.Lock()
+	defer t.Unlock()
+	t.close()
+}
+
+// HandleDisconnect registers a function that is called when the device
+// disconnects.
+func (t *TCode) HandleDisconnect(fnc func()) {
+	t.Lock()
+	defer t.Unlock()
+	t.disconnectFunc = fnc
+}
+
+// Capabilities implements Device.
+func (t *TCode) Capabilities() Capability {
+	return Linear | MultiAxis
+}
+
+// Move moves to position (0-99) with the given Launch speed (20-99.)
+func (t *TCode) Move(position, speed int) {
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.write(linearCommand("L0", t.position, position, speed)); err != nil {
+		log.Printf("T-Code write error: %v", err)
+		t.close()
+		return
+	}
+	t.position = clampPosition(position)
+}
+
+// MoveAxis moves axis (eg R0, R1 or R2) to position (0-99) with the given
+// Launch speed (20-99.) Axes start in the center position.
+func (t *TCode) MoveAxis(axis string, position, speed int) {
+	if axis == protocol.AxisStroke {
+		t.Move(position, speed)
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	from, ok := t.axes[axis]
+	if !ok {
+		from = 50
+	}
+	if err := t.write(linearCommand(axis, from, position, speed)); err != nil {
+		log.Printf("T-Code write error: %v", err)
+		t.close()
+		return
+	}
+	t.axes[axis] = clampPosition(position)
+}
+
+// write sends a command to the serial port.
+func (t *TCode) write(cmd string) error {
+	if t.port == nil {
+		return ErrNotConnected
+	}
+	_, err := io.WriteString(t.port, cmd)
+	return err
+}
+
+// close closes the serial port and notifies the disconnect handler.
+func (t *TCode) close() {
+	if t.port == nil {
+		return
+	}
+	t.port.Close()
+	t.port = nil
+	if t.disconnectFunc != nil {
+		// A failed write closes the port from Move, in the playback
+		// goroutine the handler waits for.
+		go t.disconnectFunc()
+	}
+}
+
+// linearCommand returns the T-Code command to move axis from position from
+// to position to (0-99) at the given Launch speed.
+func linearCommand(axis string, from, to, speed int) string {
+	from, to = clampPosition(from), clampPosition(to)
+	dist := to - from
+	if dist < 0 {
+		dist = -dist
+	}
+	interval := funscript.Duration(dist, speed).Nanoseconds() / 1e6
+	return fmt.Sprintf("%s%04dI%d\n", axis, to*9999/99, interval)
+}
+
+// clampPosition limits p to the position range of the Launch (0-99.)
+func clampPosition(p int) int {
+	if p < 0 {
+		return 0
+	}
+	if p > 99 {
+		return 99
+	}
+	return p
+}
@@ -0,0 +1,105 @@
+//go:build linux
+// +build linux
+
+package device
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// openPTY opens a new pseudo terminal and returns the master and the path of
+// the slave.
+func openPTY() (*os.File, string, error) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, m.Fd(),
+		syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		m.Close()
+		return nil, "", errno
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, m.Fd(),
+		syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		m.Close()
+		return nil, "", errno
+	}
+	return m, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+func TestLinearCommand(t *testing.T) {
+	cases := []struct {
+		From, To, Speed int
+		Want            string
+	}{
+		{0, 99, 20, "L09999I962\n"},
+		{99, 0, 80, "L00000I257\n"},
+		{5, 50, 50, "L05050I183\n"},
+		{50, 50, 50, "L05050I0\n"},
+		{-10, 200, 50, "L09999I403\n"},
+	}
+	for i, c := range cases {
+		got := linearCommand("L0", c.From, c.To, c.Speed)
+		if got != c.Want {
+			t.Errorf("case %d: want %q, got %q", i, c.Want, got)
+		}
+	}
+}
+
+func TestTCode(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	defer master.Close()
+
+	tc := NewTCode(slave, DefaultBaudRate)
+	disconnected := make(chan struct{})
+	tc.HandleDisconnect(func() {
+		close(disconnected)
+	})
+	if err := tc.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		s := bufio.NewScanner(master)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}()
+
+	tc.Move(99, 20)
+	tc.Move(0, 80)
+	tc.MoveAxis("R1", 99, 50)
+	tc.MoveAxis("R1", 50, 50)
+	tc.MoveAxis("L0", 50, 50)
+	for _, want := range []string{"L09999I962", "L00000I257",
+		"R19999I199", "R15050I199", "L05050I203"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("want %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+
+	tc.Disconnect()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Errorf("disconnect handler not called")
+	}
+}
@@ -0,0 +1,115 @@
+package device
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// vibrateHold is the time a vibration continues after the move it was
+// calculated for would have completed.
+var vibrateHold = time.Millisecond * 250
+
+// DefaultVibrationCurve is the curve used when none is configured.
+var DefaultVibrationCurve = VibrationCurve{
+	Exponent: 1,
+	Max:      1,
+}
+
+// VibrationCurve maps the moves of a stroker script to vibration intensity.
+//
+// The raw intensity of a move is its speed scaled by the square root of the
+// stroke distance, so both faster and longer strokes vibrate stronger. The raw
+// value (0.0-1.0) is raised to the power Exponent and scaled to Max:
+//
+//	intensity = Max * (speed/99 * sqrt(distance/99))^Exponent
+//
+// An Exponent below 1 makes slow strokes more noticeable, above 1 it saves
+// the strongest vibrations for the fastest strokes.
+type VibrationCurve struct {
+	Exponent float64 // Shape of the curve, 1 is linear
+	Max      float64 // Highest intensity (0.0-1.0)
+}
+
+// Intensity returns the vibration intensity (0.0-1.0) for a move over dist
+// percent at the Launch speed spd.
+func (c VibrationCurve) Intensity(dist, spd int) float64 {
+	if dist < 0 {
+		dist = -dist
+	}
+	d := math.Min(float64(dist), 99) / 99
+	s := math.Min(math.Max(float64(spd), 0), 99) / 99
+	raw := s * math.Sqrt(d)
+	if raw <= 0 {
+		return 0
+	}
+	exp := c.Exponent
+	if exp <= 0 {
+		exp = 1
+	}
+	max := c.Max
+	if max <= 0 || max > 1 {
+		max = 1
+	}
+	return max * math.Pow(raw, exp)
+}
+
+// intensityMapper converts moves into an intensity for devices that can not
+// move to a position, like vibrators and rotators. The output is set back to
+// 0 when no new move follows in time.
+type intensityMapper struct {
+	sync.Mutex
+
+	curve    VibrationCurve
+	output   func(intensity float64, up bool)
+	position int         // last position moved to
+	moveSeq  uint64      // increased on every move
+	idle     *time.Timer // stops the output after the last move
+}
+
+// newIntensityMapper returns a mapper that sends intensities to output.
+func newIntensityMapper(curve VibrationCurve, output func(float64, bool)) *intensityMapper {
+	return &intensityMapper{
+		curve:  curve,
+		output: output,
+	}
+}
+
+// Move sets the output to the intensity matching a move to position (0-99)
+// with the given Launch speed.
+func (m *intensityMapper) Move(position, speed int) {
+	m.Lock()
+	defer m.Unlock()
+
+	position = clampPosition(position)
+	dist := position - m.position
+	up := dist > 0
+	if dist < 0 {
+		dist = -dist
+	}
+	m.position = position
+	m.output(m.curve.Intensity(dist, speed), up)
+
+	// Stop the output when no new move follows this one.
+	m.moveSeq++
+	seq := m.moveSeq
+	if m.idle != nil {
+		m.idle.Stop()
+	}
+	m.idle = time.AfterFunc(funscript.Duration(dist, speed)+vibrateHold, func() {
+		m.Lock()
+		defer m.Unlock()
+		if m.moveSeq == seq {
+			m.output(0, up)
+		}
+	})
+}
+
+// SetCurve changes the curve used for new moves.
+func (m *intensityMapper) SetCurve(c VibrationCurve) {
+	m.Lock()
+	defer m.Unlock()
+	m.curve = c
+}
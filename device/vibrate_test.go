@@ -0,0 +1,59 @@
+package device
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+func TestVibrationCurve(t *testing.T) {
+	cases := []struct {
+		Curve     VibrationCurve
+		Dist, Spd int
+		Want      float64
+	}{
+		{DefaultVibrationCurve, 0, 50, 0},
+		{DefaultVibrationCurve, 99, 99, 1},
+		{DefaultVibrationCurve, -99, 99, 1},
+		{DefaultVibrationCurve, 99, 0, 0},
+		{VibrationCurve{Exponent: 1, Max: 0.5}, 99, 99, 0.5},
+		{VibrationCurve{Exponent: 2, Max: 1}, 99, 70, 0.5},
+	}
+	for i, c := range cases {
+		got := c.Curve.Intensity(c.Dist, c.Spd)
+		if got < c.Want-0.01 || got > c.Want+0.01 {
+			t.Errorf("case %d: want %.2f, got %.2f", i, c.Want, got)
+		}
+	}
+}
+
+func TestIntensityMapper(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []float64
+		dir []bool
+	)
+	m := newIntensityMapper(DefaultVibrationCurve, func(i float64, up bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, i)
+		dir = append(dir, up)
+	})
+	m.Move(99, 99)
+	// Wait for the output to stop after the move
+	time.Sleep(funscript.Duration(99, 99) + vibrateHold + time.Millisecond*100)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 outputs, got %d", len(got))
+	}
+	if got[0] < 0.99 || got[1] != 0 {
+		t.Errorf("unexpected intensities: %v", got)
+	}
+	if !dir[0] {
+		t.Errorf("move up reported as down")
+	}
+}
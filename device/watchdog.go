@@ -0,0 +1,129 @@
+package device
+
+import (
+	"sync"
+	"time"
+)
+
+// Actions the watchdog takes when heartbeats stop arriving.
+const (
+	// WatchdogPause pauses playback, or stops it when the player can not
+	// be paused.
+	WatchdogPause = "pause"
+	// WatchdogStop stops playback.
+	WatchdogStop = "stop"
+)
+
+// EventWatchdog is published when the watchdog halted playback.
+const EventWatchdog = "watchdog"
+
+// WatchdogStatus is the state of the watchdog.
+type WatchdogStatus struct {
+	Enabled bool   `json:"enabled"`
+	Timeout string `json:"timeout,omitempty"`
+	Action  string `json:"action,omitempty"`
+	// LastHeartbeat is nil until the watchdog is started or fed.
+	LastHeartbeat *time.Time `json:"lastheartbeat,omitempty"`
+	Expired       bool       `json:"expired"`
+}
+
+// watchdog calls expire when it is not fed a heartbeat within timeout while
+// it is running.
+type watchdog struct {
+	sync.Mutex
+
+	timeout time.Duration
+	action  string
+	expire  func(action string)
+	timer   *time.Timer
+	last    time.Time // time of the last heartbeat
+	expired bool      // expired since the last start
+}
+
+// Configure sets the timeout (0 disables the watchdog) and action.
+func (w *watchdog) Configure(timeout time.Duration, action string) {
+	w.Lock()
+	defer w.Unlock()
+	w.timeout = timeout
+	w.action = action
+	if timeout <= 0 && w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// Start arms the watchdog.
+func (w *watchdog) Start() {
+	w.Lock()
+	defer w.Unlock()
+	w.last = time.Now()
+	w.expired = false
+	w.arm()
+}
+
+// Stop disarms the watchdog.
+func (w *watchdog) Stop() {
+	w.Lock()
+	defer w.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// Beat records a heartbeat and restarts the timeout when the watchdog is
+// armed.
+func (w *watchdog) Beat() {
+	w.Lock()
+	defer w.Unlock()
+	w.last = time.Now()
+	if w.timer != nil {
+		w.arm()
+	}
+}
+
+// arm (re)starts the timer.
+func (w *watchdog) arm() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.timeout <= 0 {
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(w.timeout, func() {
+		w.Lock()
+		if w.timer != t {
+			// Rearmed or stopped in the meantime
+			w.Unlock()
+			return
+		}
+		w.timer = nil
+		w.expired = true
+		action := w.action
+		w.Unlock()
+		w.expire(action)
+	})
+	w.timer = t
+}
+
+// Status returns the state of the watchdog.
+func (w *watchdog) Status() WatchdogStatus {
+	w.Lock()
+	defer w.Unlock()
+	if w.timeout <= 0 {
+		return WatchdogStatus{}
+	}
+	s := WatchdogStatus{
+		Enabled: true,
+		Timeout: w.timeout.String(),
+		Action:  w.action,
+		Expired: w.expired,
+	}
+	if !w.last.IsZero() {
+		last := w.last
+		s.LastHeartbeat = &last
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// longScript returns a script of n actions 50ms apart.
+func longScript(n int) []protocol.TimedAction {
+	s := make([]protocol.TimedAction, n)
+	for i := range s {
+		s[i] = protocol.TimedAction{
+			Action: protocol.Action{
+				Position: i % 2 * 90,
+				Speed:    50,
+			},
+			Time: time.Millisecond * 50 * time.Duration(i+1),
+		}
+	}
+	return s
+}
+
+func TestWatchdog(t *testing.T) {
+	fake := &fakeLaunch{}
+	lm := NewLaunchManager(NewLaunchDevice(fake))
+	lm.SetWatchdog(time.Millisecond*100, WatchdogPause)
+	events := lm.Events()
+	p := protocol.NewTimedActionsPlayer()
+	p.Script = longScript(20)
+	lm.SetScriptPlayer(p)
+
+	if s := lm.Status(); s.Watchdog.LastHeartbeat != nil {
+		t.Errorf("heartbeat before playback: %v", s.Watchdog.LastHeartbeat)
+	}
+	if err := lm.Play(); err != nil {
+		t.Fatal(err)
+	}
+	// Heartbeats keep it playing
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond * 50)
+		lm.Heartbeat()
+	}
+	if s := lm.Status(); !s.Playing || s.Paused || s.Watchdog.Expired {
+		t.Errorf("playback halted while receiving heartbeats: %+v", s)
+	} else if s.Watchdog.LastHeartbeat == nil {
+		t.Errorf("heartbeats not recorded: %+v", s.Watchdog)
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != EventWatchdog {
+			t.Errorf("unexpected event: %v", e)
+		}
+	case <-time.After(time.Millisecond * 500):
+		t.Fatalf("watchdog did not expire")
+	}
+	s := lm.Status()
+	if !s.Playing || !s.Paused || !s.Watchdog.Expired {
+		t.Errorf("playback not paused by watchdog: %+v", s)
+	}
+
+	// Resuming rearms the watchdog
+	if err := lm.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if s := lm.Status(); s.Paused || s.Watchdog.Expired {
+		t.Errorf("resume did not reset status: %+v", s)
+	}
+	lm.SetWatchdog(time.Millisecond*100, WatchdogStop)
+	select {
+	case <-events:
+	case <-time.After(time.Millisecond * 500):
+		t.Fatalf("watchdog did not expire")
+	}
+	if s := lm.Status(); s.Playing {
+		t.Errorf("playback not stopped by watchdog: %+v", s)
+	}
+}
@@ -10,10 +10,16 @@ $(document).ready(function(loc, client) {
 
     var fleshlight = $( "#fleshlight" ).fleshlight();
     console.log("Acquiring websocket");
-    var launchSocket = new WebSocket("ws://" + loc.host + "/v1/socket");
+    var scheme = loc.protocol === "https:" ? "wss://" : "ws://";
+    var launchSocket = new WebSocket(scheme + loc.host + "/v1/socket");
     launchSocket.onmessage = function(event) {
         console.log(event.data);
         var action = JSON.parse(event.data);
+        if (action.axis || action.event) {
+            // Only the stroke is shown, not the other axes or safety
+            // violations
+            return;
+        }
         fleshlight.fleshlight("move", action.pos, action.spd);
     }
 }(location, launchcontrolClient));
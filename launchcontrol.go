@@ -10,14 +10,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/funjack/golaunch"
 	"github.com/funjack/launchcontrol/control"
 	"github.com/funjack/launchcontrol/device"
+	"github.com/funjack/launchcontrol/metrics"
 )
 
 // Update license.go
@@ -26,12 +30,44 @@ import (
 // Update version.go containing the "version" variable
 //go:generate go run tools/gen-version.go
 
+// readHeaderTimeout is the time clients have to send the request headers.
+const readHeaderTimeout = time.Second * 10
+
 var (
+	config   = flag.String("config", "", "configuration file (default $XDG_CONFIG_HOME/launchcontrol/config.json)")
 	listen   = flag.String("listen", "127.0.0.1:6969", "listen address")
+	liveUDP  = flag.String("live-udp", "", "listen address for live positions over UDP (eg 127.0.0.1:6970)")
 	buttplug = flag.String("buttplug", "", "buttplug.io websocket server address (eg ws://localhost:12345/buttplug)")
 	ca       = flag.String("ca", "", "certificate authority in PEM format")
 	insecure = flag.Bool("insecure", false, "skip certificate verification")
 	noact    = flag.Bool("noact", false, "simulate launch on console")
+	tcode    = flag.String("tcode", "", "T-Code serial port of a OSR2/SR6 style stroker (eg /dev/ttyUSB0)")
+	baud     = flag.Int("tcode-baud", device.DefaultBaudRate, "T-Code serial port speed")
+	vibrate  = flag.Bool("vibrate", false, "drive vibrators connected to the -buttplug server")
+	vibCurve = flag.Float64("vibrate-curve", device.DefaultVibrationCurve.Exponent, "vibration curve exponent (<1 stronger slow strokes, >1 weaker)")
+	vibMax   = flag.Float64("vibrate-max", device.DefaultVibrationCurve.Max, "maximum vibration intensity (0.0-1.0)")
+	park     = flag.String("park", "off", "position to return to when playback ends: top, bottom, off or 0-99")
+	parkSpd  = flag.Int("park-speed", device.DefaultParkSpeed, "speed used to return to the -park position")
+	safeIntv = flag.Duration("safety-interval", device.DefaultSafetyLimits.MinInterval, "shortest time between two moves, moves that follow sooner are dropped")
+	safeWin  = flag.Duration("safety-window", device.DefaultSafetyLimits.Window, "period the sustained speed is measured over")
+	safeSpd  = flag.Int("safety-max-speed", device.DefaultSafetyLimits.MaxSustainedSpeed, "highest average speed over the -safety-window (0 disables)")
+	safeMin  = flag.Int("safety-min", device.DefaultSafetyLimits.PositionMin, "lowest position the device moves to")
+	safeMax  = flag.Int("safety-max", device.DefaultSafetyLimits.PositionMax, "highest position the device moves to")
+	watchdog = flag.Duration("watchdog", 0, "halt playback when no heartbeat is received within this time (0 disables)")
+	wdAction = flag.String("watchdog-action", device.WatchdogPause, "action when the -watchdog expires: pause or stop")
+	profiles = flag.String("profiles", "", "personalization profiles file (JSON)")
+	auth     = flag.Bool("auth", false, "require clients to authenticate with a token")
+	tokens   = flag.String("tokens", "", "file to store client tokens in (JSON)")
+	origins  = flag.String("origins", "", "comma separated origins allowed to use the API from a browser (* for all)")
+	tlsCert  = flag.String("tls-cert", "", "serve HTTPS using this certificate in PEM format")
+	tlsKey   = flag.String("tls-key", "", "private key in PEM format for the -tls-cert certificate")
+	tlsSelf  = flag.Bool("tls-self-signed", false, "serve HTTPS with a generated self-signed certificate (stored in -tls-cert/-tls-key when set)")
+	maxSize  = flag.Int("max-script-size", control.DefaultLimits.MaxScriptSize, "maximum size of an uploaded script in bytes (0 disables)")
+	maxActs  = flag.Int("max-actions", control.DefaultLimits.MaxActions, "maximum number of actions of a loaded script (0 disables)")
+	loadTO   = flag.Duration("load-timeout", control.DefaultLimits.LoadTimeout, "time to receive and load an uploaded script (0 disables)")
+	readTO   = flag.Duration("read-timeout", 0, "time to read a request including the body, also ends live Kiiroo POST streams (0 disables)")
+	writeTO  = flag.Duration("write-timeout", 0, "time to write a response after the request is read (0 disables)")
+	idleTO   = flag.Duration("idle-timeout", time.Minute*2, "time to keep idle connections open (0 uses the -read-timeout)")
 	lics     = flag.Bool("licenses", false, "show licenses")
 	ver      = flag.Bool("version", false, "show version")
 )
@@ -54,55 +90,226 @@ func main() {
 		fmt.Println(licenses)
 		os.Exit(0)
 	}
+	if flag.NArg() > 0 {
+		if flag.Arg(0) == "config" {
+			os.Exit(configCommand(*config, flag.Args()[1:]))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(*config)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+	if err := applyConfig(cfg); err != nil {
+		log.Fatalf("error applying config: %v", err)
+	}
+	// Validate the flags together with the config, so values given on
+	// the commandline are checked as well.
+	if errs := cfg.merged().Validate(); len(errs) > 0 {
+		log.Fatalf("invalid config: %v", errs)
+	}
 
 	log.Println("Launchcontrol: Get ready for the Launch")
 
-	var l golaunch.Launch
+	var d device.Device
 	if *noact {
-		l = &launchMock{}
+		d = device.NewLaunchDevice(&launchMock{})
+	} else if *tcode != "" {
+		d = device.NewTCode(*tcode, *baud)
+		defer d.Disconnect()
 	} else if *buttplug != "" {
 		tlscfg, err := createTLSConfig(*ca, *insecure)
 		if err != nil {
 			log.Fatalf("error creating tls config: %v", err)
 		}
-		ctx := context.Background()
-		l = golaunch.NewButtplugLaunch(ctx, *buttplug, "Launchcontrol", tlscfg)
+		if *vibrate {
+			d = device.NewButtplugVibrator(*buttplug, "Launchcontrol",
+				tlscfg)
+		} else {
+			ctx := context.Background()
+			d = device.NewLaunchDevice(golaunch.NewButtplugLaunch(ctx,
+				*buttplug, "Launchcontrol", tlscfg))
+		}
 	} else {
-		l = golaunch.NewLaunch()
-		defer l.Disconnect()
+		d = device.NewLaunchDevice(golaunch.NewLaunch())
+		defer d.Disconnect()
 	}
 
-	lm := device.NewLaunchManager(l)
+	lm := device.NewLaunchManager(d)
+	lm.SetVibrationCurve(device.VibrationCurve{
+		Exponent: *vibCurve,
+		Max:      *vibMax,
+	})
+	parkPos, err := parsePark(*park)
+	if err != nil {
+		log.Fatalf("invalid park position: %v", err)
+	}
+	lm.SetPark(parkPos, *parkSpd)
+	safety, err := safetyLimits()
+	if err != nil {
+		log.Fatalf("invalid safety limits: %v", err)
+	}
+	lm.SetSafetyLimits(safety)
+	if *wdAction != device.WatchdogPause && *wdAction != device.WatchdogStop {
+		log.Fatalf("invalid watchdog action: %q", *wdAction)
+	}
+	lm.SetWatchdog(*watchdog, *wdAction)
+	log.Printf("Device capabilities: %s", lm.Capabilities())
 	c := control.NewController(lm)
+	ps, err := control.NewProfileStore(*profiles)
+	if err != nil {
+		log.Fatalf("error loading profiles: %v", err)
+	}
+	c.SetProfileStore(ps)
 
-	http.Handle("/v1/play", logger(http.HandlerFunc(c.PlayHandler)))
-	http.Handle("/v1/stop", logger(http.HandlerFunc(c.StopHandler)))
-	http.Handle("/v1/pause", logger(http.HandlerFunc(c.PauseHandler)))
-	http.Handle("/v1/resume", logger(http.HandlerFunc(c.ResumeHandler)))
-	http.Handle("/v1/skip", logger(http.HandlerFunc(c.SkipHandler)))
-	http.Handle("/v1/dump", logger(http.HandlerFunc(c.DumpHandler)))
-	http.Handle("/v1/socket", logger(http.HandlerFunc(c.WebsocketHandler)))
+	var allowed control.Origins
+	if *origins != "" {
+		allowed = strings.Split(*origins, ",")
+	}
+	c.SetAllowedOrigins(allowed)
+	c.SetLimits(control.Limits{
+		MaxScriptSize: *maxSize,
+		MaxActions:    *maxActs,
+		LoadTimeout:   *loadTO,
+	})
+
+	a, err := control.NewAuthenticator(*tokens)
+	if err != nil {
+		log.Fatalf("error loading tokens: %v", err)
+	}
+	if cfg.Auth.Key != "" {
+		a.AddToken("apikey", cfg.Auth.Key)
+	}
+	api := func(h http.HandlerFunc) http.Handler {
+		if *auth {
+			return logger(allowed.Handler(a.Handler(h)))
+		}
+		return logger(allowed.Handler(h))
+	}
+
+	http.Handle("/v1/play", api(c.PlayHandler))
+	http.Handle("/v1/stop", api(c.StopHandler))
+	http.Handle("/v1/pause", api(c.PauseHandler))
+	http.Handle("/v1/resume", api(c.ResumeHandler))
+	http.Handle("/v1/skip", api(c.SkipHandler))
+	http.Handle("/v1/chapters", api(c.ChaptersHandler))
+	http.Handle("/v1/chapters/next", api(c.NextChapterHandler))
+	http.Handle("/v1/chapters/previous", api(c.PreviousChapterHandler))
+	http.Handle("/v1/tune", api(c.TuneHandler))
+	http.Handle("/v1/dump", api(c.DumpHandler))
+	http.Handle("/v1/queue", api(c.QueueHandler))
+	http.Handle("/v1/queue/next", api(c.NextHandler))
+	http.Handle("/v1/queue/previous", api(c.PreviousHandler))
+	http.Handle("/v1/estop", api(c.EmergencyStopHandler))
+	http.Handle("/v1/heartbeat", api(c.HeartbeatHandler))
+	http.Handle("/v1/status", api(c.StatusHandler))
+	http.Handle("/v1/profiles", api(c.ProfilesHandler))
+	http.Handle("/v1/profiles/", api(c.ProfilesHandler))
+	http.Handle("/v1/socket", api(c.WebsocketHandler))
+	http.Handle("/v1/stream/kiiroo", api(c.KiirooStreamHandler))
+	http.Handle("/metrics", api(metrics.Handler().ServeHTTP))
+	if *auth {
+		http.Handle("/v1/pair", logger(allowed.Handler(
+			http.HandlerFunc(a.PairHandler))))
+	}
 	http.Handle("/", logger(http.FileServer(assetFS())))
 
+	if *liveUDP != "" {
+		conn, err := net.ListenPacket("udp", *liveUDP)
+		if err != nil {
+			log.Fatalf("error listening for live positions: %v", err)
+		}
+		log.Printf("Listening for live positions on %s (UDP)\n", *liveUDP)
+		go func() {
+			log.Printf("Live positions: %v", c.ServeLiveUDP(conn))
+		}()
+	}
+
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt)
 	go func() {
 		<-sig
 		log.Println("Shutting down...")
-		l.Disconnect()
 		var done = make(chan struct{})
-		lm.WaitUntilStopped(done)
+		go func() {
+			if err := lm.Stop(); err != nil {
+				log.Printf("Error stopping playback: %v", err)
+			}
+			lm.WaitUntilParked()
+			d.Disconnect()
+			lm.WaitUntilStopped(done)
+		}()
 		select {
 		case <-done:
 			log.Println("Shutdown complete.")
-		case <-time.After(time.Second * 2):
+		case <-time.After(time.Second * 3):
 			log.Println("Forcefully shutdown.")
 		}
 		os.Exit(0)
 	}()
 
+	// Websockets clear the deadlines of the read and write timeouts.
+	srv := &http.Server{
+		Addr:              *listen,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       *readTO,
+		WriteTimeout:      *writeTO,
+		IdleTimeout:       *idleTO,
+	}
+	if *tlsSelf || *tlsCert != "" || *tlsKey != "" {
+		srv.TLSConfig, err = createServerTLSConfig(*tlsCert, *tlsKey,
+			*tlsSelf, *listen)
+		if err != nil {
+			log.Fatalf("error creating server tls config: %v", err)
+		}
+		log.Printf("Listening on %s (TLS)\n", *listen)
+		log.Fatal(srv.ListenAndServeTLS("", ""))
+	}
 	log.Printf("Listening on %s\n", *listen)
-	log.Fatal(http.ListenAndServe(*listen, nil))
+	log.Fatal(srv.ListenAndServe())
+}
+
+// parsePark returns the device park position for s, which is top, bottom,
+// off or a position between 0 and 99.
+func parsePark(s string) (int, error) {
+	switch s {
+	case "top":
+		return device.ParkTop, nil
+	case "bottom":
+		return device.ParkBottom, nil
+	case "off", "":
+		return device.ParkOff, nil
+	}
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 99 {
+		return device.ParkOff, fmt.Errorf(
+			"%q is not top, bottom, off or 0-99", s)
+	}
+	return p, nil
+}
+
+// safetyLimits returns the safety limits set with the -safety flags.
+func safetyLimits() (device.SafetyLimits, error) {
+	l := device.SafetyLimits{
+		MinInterval:       *safeIntv,
+		Window:            *safeWin,
+		MaxSustainedSpeed: *safeSpd,
+		PositionMin:       *safeMin,
+		PositionMax:       *safeMax,
+	}
+	switch {
+	case l.MinInterval < 0 || l.Window < 0:
+		return l, errors.New("durations must be positive")
+	case l.MaxSustainedSpeed < 0 || l.MaxSustainedSpeed > 99:
+		return l, fmt.Errorf("speed %d is not 0-99", l.MaxSustainedSpeed)
+	case l.PositionMin < 0 || l.PositionMax > 99 ||
+		l.PositionMin >= l.PositionMax:
+		return l, fmt.Errorf("positions %d-%d are not 0-99 with min "+
+			"below max", l.PositionMin, l.PositionMax)
+	}
+	return l, nil
 }
 
 // createTLSConfig creates a configuration trusting certs signed by the ca or
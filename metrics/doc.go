@@ -0,0 +1,13 @@
+/*
+Package metrics implements counters and gauges that can be exposed in the
+Prometheus text exposition format.
+
+Metrics are registered when they are created, usually as package level
+variables:
+
+	var moves = metrics.NewCounter("launchcontrol_moves_total",
+		"Number of move commands sent to the device.")
+
+All registered metrics are served by Handler.
+*/
+package metrics
@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is a single metric family that can write itself in the text
+// exposition format.
+type metric interface {
+	Name() string
+	write(w io.Writer)
+}
+
+// registry contains all created metrics.
+var registry = struct {
+	sync.Mutex
+	metrics map[string]metric
+}{
+	metrics: make(map[string]metric),
+}
+
+// register adds m to the registry. Registering the same name twice is a
+// programming error and panics.
+func register(m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.metrics[m.Name()]; ok {
+		panic("metrics: duplicate metric " + m.Name())
+	}
+	registry.metrics[m.Name()] = m
+}
+
+// Counter is a metric that can only go up.
+type Counter struct {
+	name, help string
+	value      uint64
+}
+
+// NewCounter creates and registers a new counter.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(c)
+	return c
+}
+
+// Name returns the name of the counter.
+func (c *Counter) Name() string {
+	return c.name
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// write implements the metric interface.
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	name, help string
+	bits       uint64
+}
+
+// NewGauge creates and registers a new gauge.
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	register(g)
+	return g
+}
+
+// Name returns the name of the gauge.
+func (g *Gauge) Name() string {
+	return g.name
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds v to the gauge.
+func (g *Gauge) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		new := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&g.bits, old, new) {
+			return
+		}
+	}
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// write implements the metric interface.
+func (g *Gauge) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Value()))
+}
+
+// CounterVec is a counter partitioned by the value of a single label.
+type CounterVec struct {
+	sync.Mutex
+
+	name, help, label string
+	counters          map[string]*Counter
+}
+
+// NewCounterVec creates and registers a new counter with a label.
+func NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{
+		name:     name,
+		help:     help,
+		label:    label,
+		counters: make(map[string]*Counter),
+	}
+	register(c)
+	return c
+}
+
+// Name returns the name of the counter.
+func (c *CounterVec) Name() string {
+	return c.name
+}
+
+// With returns the counter for the given label value.
+func (c *CounterVec) With(value string) *Counter {
+	c.Lock()
+	defer c.Unlock()
+	counter, ok := c.counters[value]
+	if !ok {
+		counter = &Counter{name: c.name}
+		c.counters[value] = counter
+	}
+	return counter
+}
+
+// write implements the metric interface.
+func (c *CounterVec) write(w io.Writer) {
+	c.Lock()
+	values := make([]string, 0, len(c.counters))
+	for v := range c.counters {
+		values = append(values, v)
+	}
+	c.Unlock()
+	sort.Strings(values)
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, v := range values {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label,
+			labelEscaper.Replace(v), c.With(v).Value())
+	}
+}
+
+// WriteTo writes all registered metrics in the text exposition format.
+func WriteTo(w io.Writer) error {
+	registry.Lock()
+	metrics := make([]metric, 0, len(registry.metrics))
+	for _, m := range registry.metrics {
+		metrics = append(metrics, m)
+	}
+	registry.Unlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name() < metrics[j].Name()
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns a http.Handler serving all registered metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteTo(w)
+	})
+}
+
+// labelEscaper escapes label values, only backslash, double quote and line
+// feed are escaped in the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(w io.Writer, name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// formatFloat formats v as a exposition format value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+// freshRegistry replaces the registry with an empty one, tests can register
+// the same metrics each run. The returned func restores the registry.
+func freshRegistry() func() {
+	registry.Lock()
+	defer registry.Unlock()
+	saved := registry.metrics
+	registry.metrics = make(map[string]metric)
+	return func() {
+		registry.Lock()
+		defer registry.Unlock()
+		registry.metrics = saved
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	defer freshRegistry()()
+	c := NewCounter("test_counter_total", "A test counter.")
+	g := NewGauge("test_gauge", "A test gauge.")
+	v := NewCounterVec("test_vec_total", "A test counter with label.", "format")
+
+	c.Inc()
+	c.Add(2)
+	g.Set(1.5)
+	g.Dec()
+	v.With("raw").Inc()
+	v.With("funscript").Add(4)
+	v.With("a\"b\\c\nd é").Inc()
+
+	var buf bytes.Buffer
+	if err := WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_counter_total A test counter.
+# TYPE test_counter_total counter
+test_counter_total 3
+# HELP test_gauge A test gauge.
+# TYPE test_gauge gauge
+test_gauge 0.5
+# HELP test_vec_total A test counter with label.
+# TYPE test_vec_total counter
+test_vec_total{format="a\"b\\c\nd é"} 1
+test_vec_total{format="funscript"} 4
+test_vec_total{format="raw"} 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output, want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	defer freshRegistry()()
+	NewCounter("test_x_duplicate", "")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a duplicate metric did not panic")
+		}
+	}()
+	NewGauge("test_x_duplicate", "")
+}
@@ -2,22 +2,31 @@ package protocol
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
+// maxAt is the largest time in milliseconds that fits a Duration.
+const maxAt = int64(1<<63-1) / int64(time.Millisecond)
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (ta *TimedAction) UnmarshalJSON(in []byte) error {
 	var c struct {
-		At  int64
-		Pos int
-		Spd int
+		At   int64
+		Pos  int
+		Spd  int
+		Axis string
 	}
 	err := json.Unmarshal(in, &c)
 	if err != nil {
 		return err
 	}
+	if c.At > maxAt || c.At < -maxAt {
+		return fmt.Errorf("time %d out of range", c.At)
+	}
 	ta.Position = c.Pos
 	ta.Speed = c.Spd
+	ta.Axis = c.Axis
 	ta.Time = time.Duration(c.At) * time.Millisecond
 	return nil
 }
@@ -25,13 +34,93 @@ func (ta *TimedAction) UnmarshalJSON(in []byte) error {
 // MarshalJSON implements the json.Marshaler interface.
 func (ta TimedAction) MarshalJSON() ([]byte, error) {
 	c := struct {
-		At  int64 `json:"at"`
-		Pos int   `json:"pos"`
-		Spd int   `json:"spd"`
+		At   int64  `json:"at"`
+		Pos  int    `json:"pos"`
+		Spd  int    `json:"spd"`
+		Axis string `json:"axis,omitempty"`
 	}{
-		At:  ta.Time.Nanoseconds() / 1e6,
-		Pos: ta.Position,
-		Spd: ta.Speed,
+		At:   ta.Time.Nanoseconds() / 1e6,
+		Pos:  ta.Position,
+		Spd:  ta.Speed,
+		Axis: ta.Axis,
 	}
 	return json.Marshal(&c)
 }
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The start and end
+// times are timestamps (HH:MM:SS.mmm) or milliseconds.
+func (c *Chapter) UnmarshalJSON(in []byte) error {
+	var v struct {
+		Name      string      `json:"name"`
+		StartTime interface{} `json:"startTime"`
+		EndTime   interface{} `json:"endTime"`
+	}
+	err := json.Unmarshal(in, &v)
+	if err != nil {
+		return err
+	}
+	c.Name = v.Name
+	if c.Start, err = chapterTime(v.StartTime); err != nil {
+		return err
+	}
+	c.End, err = chapterTime(v.EndTime)
+	return err
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c Chapter) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Name      string `json:"name"`
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+	}{
+		Name:      c.Name,
+		StartTime: formatTimestamp(c.Start),
+		EndTime:   formatTimestamp(c.End),
+	}
+	return json.Marshal(&v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The time is a
+// timestamp (HH:MM:SS.mmm) or milliseconds.
+func (b *Bookmark) UnmarshalJSON(in []byte) error {
+	var v struct {
+		Name string      `json:"name"`
+		Time interface{} `json:"time"`
+	}
+	err := json.Unmarshal(in, &v)
+	if err != nil {
+		return err
+	}
+	b.Name = v.Name
+	b.Time, err = chapterTime(v.Time)
+	return err
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (b Bookmark) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Name string `json:"name"`
+		Time string `json:"time"`
+	}{
+		Name: b.Name,
+		Time: formatTimestamp(b.Time),
+	}
+	return json.Marshal(&v)
+}
+
+// chapterTime returns the time of a decoded chapter or bookmark timestamp.
+func chapterTime(v interface{}) (time.Duration, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return parseTimestamp(t)
+	case float64:
+		if t < 0 {
+			return 0, errTimestamp
+		}
+		return time.Duration(t) * time.Millisecond, nil
+	}
+	return 0, errTimestamp
+}
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -30,6 +31,17 @@ var jsonTestData = []struct {
 			},
 		},
 	},
+	{
+		JSON: `{"at":200,"pos":30,"spd":40,"axis":"R1"}`,
+		TimedAction: TimedAction{
+			Time: time.Millisecond * 200,
+			Action: Action{
+				Position: 30,
+				Speed:    40,
+				Axis:     AxisRoll,
+			},
+		},
+	},
 }
 
 func TestTimedActionUnmarshalJSON(t *testing.T) {
@@ -45,6 +57,10 @@ func TestTimedActionUnmarshalJSON(t *testing.T) {
 		}
 	}
 
+	var ta TimedAction
+	if err := ta.UnmarshalJSON([]byte(`{"at":9223372036854776,"pos":50}`)); err == nil {
+		t.Errorf("time out of range accepted: %s", ta.Time)
+	}
 }
 
 func TestTimedActionMarshalJSON(t *testing.T) {
@@ -64,3 +80,100 @@ func TestTimedActionMarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestChapterJSON(t *testing.T) {
+	cases := []struct {
+		JSON    string
+		Chapter Chapter
+	}{
+		{
+			JSON: `{"name":"Intro","startTime":"00:00:00.000","endTime":"00:01:30.500"}`,
+			Chapter: Chapter{
+				Name: "Intro",
+				End:  time.Second*90 + time.Millisecond*500,
+			},
+		},
+		{
+			JSON: `{"name":"Long","startTime":"01:02:03.004","endTime":"02:00:00.000"}`,
+			Chapter: Chapter{
+				Name:  "Long",
+				Start: time.Hour + time.Minute*2 + time.Second*3 + time.Millisecond*4,
+				End:   time.Hour * 2,
+			},
+		},
+	}
+	for i, c := range cases {
+		var ch Chapter
+		if err := json.Unmarshal([]byte(c.JSON), &ch); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if ch != c.Chapter {
+			t.Errorf("case %d: want %+v, got %+v", i, c.Chapter, ch)
+		}
+		out, err := json.Marshal(ch)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if string(out) != c.JSON {
+			t.Errorf("case %d: want %s, got %s", i, c.JSON, out)
+		}
+	}
+
+	// Other time notations
+	alternatives := map[string]Chapter{
+		`{"name":"ms","startTime":1500,"endTime":61000}`: {
+			Name: "ms", Start: time.Millisecond * 1500, End: time.Second * 61,
+		},
+		`{"name":"short","startTime":"1:05","endTime":"90.25"}`: {
+			Name: "short", Start: time.Second * 65, End: time.Millisecond * 90250,
+		},
+		`{"name":"open"}`: {
+			Name: "open",
+		},
+	}
+	for in, want := range alternatives {
+		var ch Chapter
+		if err := json.Unmarshal([]byte(in), &ch); err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if ch != want {
+			t.Errorf("%s: want %+v, got %+v", in, want, ch)
+		}
+	}
+
+	for _, in := range []string{
+		`{"startTime":"a:b"}`,
+		`{"startTime":"1:2:3:4"}`,
+		`{"startTime":-1}`,
+		`{"endTime":true}`,
+	} {
+		var ch Chapter
+		if err := json.Unmarshal([]byte(in), &ch); err == nil {
+			t.Errorf("%s: parsed invalid chapter", in)
+		}
+	}
+}
+
+func TestBookmarkJSON(t *testing.T) {
+	var b Bookmark
+	in := `{"name":"Climax","time":"00:12:34.500"}`
+	if err := json.Unmarshal([]byte(in), &b); err != nil {
+		t.Fatal(err)
+	}
+	want := Bookmark{
+		Name: "Climax",
+		Time: time.Minute*12 + time.Second*34 + time.Millisecond*500,
+	}
+	if b != want {
+		t.Errorf("want %+v, got %+v", want, b)
+	}
+	out, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("want %s, got %s", in, out)
+	}
+	if err := json.Unmarshal([]byte(`{"time":"soon"}`), &b); err == nil {
+		t.Errorf("invalid time accepted")
+	}
+}
@@ -0,0 +1,179 @@
+package funscript
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// Extension is the filename extension of Funscripts.
+const Extension = ".funscript"
+
+// axisNames are the axis names used in the filenames of a set of scripts.
+var axisNames = map[string]string{
+	"stroke": protocol.AxisStroke,
+	"surge":  protocol.AxisSurge,
+	"sway":   protocol.AxisSway,
+	"twist":  protocol.AxisTwist,
+	"roll":   protocol.AxisRoll,
+	"pitch":  protocol.AxisPitch,
+	"vib":    protocol.AxisVibrate,
+}
+
+// AxisFromFilename returns the axis of the script named name in a set of
+// scripts (eg R1 for video.roll.funscript.) The second return value is false
+// when name is not a Funscript.
+func AxisFromFilename(name string) (string, bool) {
+	_, axis, ok := splitFilename(name)
+	return axis, ok
+}
+
+// SetName returns the name shared by all scripts in the set that the script
+// named name belongs to (eg path/video for path/video.roll.funscript.) The
+// second return value is false when name is not a Funscript.
+func SetName(name string) (string, bool) {
+	set, _, ok := splitFilename(name)
+	return set, ok
+}
+
+// splitFilename splits the name of a Funscript into the name of the set and
+// the axis.
+func splitFilename(name string) (string, string, bool) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if !strings.HasSuffix(strings.ToLower(name), Extension) {
+		return "", "", false
+	}
+	set := name[:len(name)-len(Extension)]
+	suffix := path.Ext(path.Base(set))
+	if suffix == "" {
+		return set, protocol.AxisStroke, true
+	}
+	trimmed := set[:len(set)-len(suffix)]
+	suffix = suffix[1:]
+	if axis, ok := axisNames[strings.ToLower(suffix)]; ok {
+		return trimmed, axis, true
+	}
+	if id := strings.ToUpper(suffix); isAxisID(id) {
+		return trimmed, id, true
+	}
+	// Dots in the name of the video
+	return set, protocol.AxisStroke, true
+}
+
+// isAxisID returns true if id is a T-Code axis name (eg L0 or R1.)
+func isAxisID(id string) bool {
+	if len(id) != 2 {
+		return false
+	}
+	switch id[0] {
+	case 'L', 'R', 'V', 'A':
+	default:
+		return false
+	}
+	return id[1] >= '0' && id[1] <= '9'
+}
+
+// AddAxis adds script o as axis to s. The stroke script sets the version,
+// inverted, range and actions of s. The metadata of the stroke script is
+// used, or else the metadata of the first script that has it.
+func (s *Script) AddAxis(axis string, o Script) {
+	if axis == "" || axis == protocol.AxisStroke {
+		s.Version = o.Version
+		s.Inverted = o.Inverted
+		s.Range = o.Range
+		s.Actions = o.Actions
+		s.Axes = append(s.Axes, o.Axes...)
+		if o.Metadata != nil {
+			s.Metadata = o.Metadata
+		}
+		return
+	}
+	if s.Metadata == nil {
+		s.Metadata = o.Metadata
+	}
+	s.Axes = append(s.Axes, Axis{
+		ID:      axis,
+		Actions: o.Actions,
+	})
+}
+
+// TimedActions creates timed actions for the axis with the default limits.
+// Axes start in the center (position 50), like T-Code devices do.
+// The second return value are statistics on the script generation.
+func (a Axis) TimedActions() (protocol.TimedActions, Stats) {
+	center := Action{At: 0, Pos: 50}
+	s, stat := Script{Actions: a.Actions}.timedActions(SpeedLimitMin,
+		SpeedLimitMax, PositionMin, PositionMax, center,
+		Range(PositionMax-PositionMin).Position(center.Pos)+PositionMin)
+	// The device is already in the center, there is no move to it
+	s = s[1:]
+	for i := range s {
+		s[i].Axis = a.ID
+	}
+	return s, stat
+}
+
+// FromTimedActions creates a Script with the timed actions as they are
+// played. The positions are not scaled back, and an action is at the time
+// the next move of the same axis starts or when the last move has finished.
+func FromTimedActions(ta protocol.TimedActions) Script {
+	var (
+		axes  = make(map[string][]protocol.TimedAction)
+		order []string
+	)
+	for _, a := range ta {
+		id := a.Axis
+		if a.IsStroke() {
+			id = protocol.AxisStroke
+		}
+		if _, ok := axes[id]; !ok {
+			order = append(order, id)
+		}
+		axes[id] = append(axes[id], a)
+	}
+
+	s := Script{
+		Version: "1.0",
+		Actions: []Action{},
+	}
+	for _, id := range order {
+		actions := axisActions(axes[id])
+		if id == protocol.AxisStroke {
+			s.Actions = actions
+			continue
+		}
+		s.Axes = append(s.Axes, Axis{
+			ID:      id,
+			Actions: actions,
+		})
+	}
+	return s
+}
+
+// axisActions converts the timed actions of a single axis into actions.
+func axisActions(ta []protocol.TimedAction) []Action {
+	actions := make([]Action, len(ta))
+	for i, a := range ta {
+		at := a.Time
+		if i+1 < len(ta) {
+			at = ta[i+1].Time
+		} else {
+			from := a.Position
+			if i > 0 {
+				from = ta[i-1].Position
+			}
+			dist := a.Position - from
+			if dist < 0 {
+				dist = -dist
+			}
+			at += Duration(dist, a.Speed)
+		}
+		actions[i] = Action{
+			At:  int64(at / time.Millisecond),
+			Pos: a.Position,
+		}
+	}
+	return actions
+}
@@ -0,0 +1,194 @@
+package funscript
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+var multiAxisScript = `{
+	"version":"1.0",
+	"actions":[
+		{"at":100,"pos":0},
+		{"at":600,"pos":100}
+	],
+	"axes":[
+		{
+			"id":"R1",
+			"actions":[
+				{"at":300,"pos":0},
+				{"at":500,"pos":100}
+			]
+		}
+	]
+}`
+
+func TestAxisFromFilename(t *testing.T) {
+	cases := []struct {
+		Name string
+		Axis string
+		OK   bool
+	}{
+		{"video.funscript", protocol.AxisStroke, true},
+		{"/path/to/video.roll.funscript", protocol.AxisRoll, true},
+		{`C:\videos\video.Twist.funscript`, protocol.AxisTwist, true},
+		{"video.pitch.funscript", protocol.AxisPitch, true},
+		{"video.r1.funscript", protocol.AxisRoll, true},
+		{"video.part.2.funscript", protocol.AxisStroke, true},
+		{"video.FUNSCRIPT", protocol.AxisStroke, true},
+		{"video.mp4", "", false},
+	}
+	for _, c := range cases {
+		axis, ok := AxisFromFilename(c.Name)
+		if axis != c.Axis || ok != c.OK {
+			t.Errorf("%s: want %q,%t, got %q,%t", c.Name, c.Axis,
+				c.OK, axis, ok)
+		}
+	}
+}
+
+func TestSetName(t *testing.T) {
+	cases := map[string]string{
+		"video.funscript":             "video",
+		"dir/video.roll.funscript":    "dir/video",
+		`dir\video.R2.funscript`:      "dir/video",
+		"video.part.2.funscript":      "video.part.2",
+		"video.part.2.sway.funscript": "video.part.2",
+	}
+	for name, want := range cases {
+		if set, ok := SetName(name); set != want || !ok {
+			t.Errorf("%s: want %q, got %q,%t", name, want, set, ok)
+		}
+	}
+	if _, ok := SetName("video.mp4"); ok {
+		t.Errorf("set name for a video")
+	}
+}
+
+func TestLoadAxes(t *testing.T) {
+	var l Loader
+	l.LimitSpeed(SpeedLimitMin, SpeedLimitMax)
+	l.LimitPosition(PositionMin, PositionMax)
+	p, err := l.Load(bytes.NewBufferString(multiAxisScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := p.(*protocol.TimedActionsPlayer).Script
+	var axes []string
+	for i, a := range script {
+		if i > 0 && a.Time < script[i-1].Time {
+			t.Errorf("action %d out of order", i)
+		}
+		axes = append(axes, a.Axis)
+	}
+	// Stroke starts at 0 and moves at 100 (pos 0 at 100 does not move),
+	// roll starts in the center and moves at 0 and 300.
+	want := []string{"", protocol.AxisRoll, "", protocol.AxisRoll}
+	if !reflect.DeepEqual(axes, want) {
+		t.Errorf("want axes %q, got %q", want, axes)
+	}
+
+	for _, s := range []string{
+		`{"actions":[],"axes":[{"id":"L0","actions":[{"at":100,"pos":50}]}]}`,
+		`{"actions":[],"axes":[{"id":"roll","actions":[{"at":100,"pos":50}]}]}`,
+		`{"actions":[]}`,
+	} {
+		if _, err := l.Load(bytes.NewBufferString(s)); err == nil {
+			t.Errorf("%s: loaded invalid script", s)
+		}
+	}
+}
+
+func TestAddAxis(t *testing.T) {
+	var s Script
+	s.AddAxis(protocol.AxisRoll, Script{
+		Actions:  []Action{{At: 1, Pos: 2}},
+		Metadata: &protocol.Metadata{Title: "roll"},
+	})
+	s.AddAxis(protocol.AxisStroke, Script{
+		Version:  "1.0",
+		Inverted: true,
+		Actions:  []Action{{At: 3, Pos: 4}},
+		Metadata: &protocol.Metadata{Title: "stroke"},
+	})
+	want := Script{
+		Version:  "1.0",
+		Inverted: true,
+		Actions:  []Action{{At: 3, Pos: 4}},
+		Metadata: &protocol.Metadata{Title: "stroke"},
+		Axes: []Axis{{
+			ID:      protocol.AxisRoll,
+			Actions: []Action{{At: 1, Pos: 2}},
+		}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("want %+v, got %+v", want, s)
+	}
+}
+
+func TestAxisTimedActions(t *testing.T) {
+	ms := time.Millisecond
+	center := Range(PositionMax-PositionMin).Position(50) + PositionMin
+	cases := []struct {
+		Name    string
+		Actions []Action
+		Want    []int // positions
+	}{
+		{"first at bottom", []Action{{At: 0, Pos: 0}, {At: 200, Pos: 100}},
+			[]int{PositionMin, PositionMax}},
+		{"first at center", []Action{{At: 100, Pos: 50}, {At: 300, Pos: 0}},
+			[]int{PositionMin}},
+		{"first above center", []Action{{At: 100, Pos: 100}},
+			[]int{PositionMax}},
+	}
+	for _, c := range cases {
+		s, _ := Axis{ID: protocol.AxisRoll, Actions: c.Actions}.TimedActions()
+		var got []int
+		for _, a := range s {
+			if a.Axis != protocol.AxisRoll {
+				t.Errorf("%s: action on axis %q", c.Name, a.Axis)
+			}
+			got = append(got, a.Position)
+		}
+		if !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("%s: want positions %v, got %v", c.Name, c.Want, got)
+		}
+	}
+	// The first move starts from the center
+	s, _ := Axis{Actions: []Action{{At: 400, Pos: 100}}}.TimedActions()
+	if want := Speed(PositionMax-center, 400*ms); s[0].Speed != want {
+		t.Errorf("speed from center, want %d, got %d", want, s[0].Speed)
+	}
+}
+
+func TestFromTimedActions(t *testing.T) {
+	ms := time.Millisecond
+	ta := protocol.TimedActions{
+		{Action: protocol.Action{Position: 5, Speed: 20}, Time: 0},
+		{Action: protocol.Action{Position: 50, Speed: 50, Axis: "R1"}, Time: 0},
+		{Action: protocol.Action{Position: 95, Speed: 50}, Time: 100 * ms},
+		{Action: protocol.Action{Position: 95, Speed: 50, Axis: "R1"}, Time: 300 * ms},
+		{Action: protocol.Action{Position: 5, Speed: 60}, Time: 600 * ms},
+	}
+	want := Script{
+		Version: "1.0",
+		Actions: []Action{
+			{At: 100, Pos: 5},
+			{At: 600, Pos: 95},
+			{At: 600 + int64(Duration(90, 60)/ms), Pos: 5},
+		},
+		Axes: []Axis{{
+			ID: "R1",
+			Actions: []Action{
+				{At: 300, Pos: 50},
+				{At: 300 + int64(Duration(45, 50)/ms), Pos: 95},
+			},
+		}},
+	}
+	if got := FromTimedActions(ta); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -12,7 +12,40 @@ positions:
 			{"pos": 0, "at": 100},
 			{"pos": 100, "at": 500},
 			...
-		]
+		],
+		"axes": [
+			{
+				"id": "R1",
+				"actions": [
+					{"pos": 50, "at": 100},
+					...
+				]
+			},
+			...
+		],
+		"metadata": {
+			"title": "Title",
+			"creator": "Creator",
+			"tags": ["tag", ...],
+			"performers": ["performer", ...],
+			"duration": 600,
+			"script_url": "https://example.com/script",
+			"chapters": [
+				{
+					"name": "Intro",
+					"startTime": "00:00:00.000",
+					"endTime": "00:01:30.000"
+				},
+				...
+			],
+			"bookmarks": [
+				{
+					"name": "Highlight",
+					"time": "00:05:00.000"
+				},
+				...
+			]
+		}
 	}
 
 	version: funscript version (optional, default="1.0")
@@ -21,6 +54,18 @@ positions:
 	actions: script for a Launch
 	  pos: position in percent (0-100)
 	  at : time to be at position in milliseconds
+	axes: scripts for other axes than the stroke (optional)
+	  id: T-Code name of the axis (eg R0, R1, R2)
+	  actions: script for the axis, like the stroke actions
+	metadata: description of the script (optional)
+	  title, creator, description, type, license, notes: strings
+	  tags, performers: lists of strings
+	  duration: length of the video in seconds
+	  script_url, video_url: where to find the script and video
+	  chapters: named sections of the script, the start and end times are
+	  timestamps (HH:MM:SS.mmm) or milliseconds
+	  bookmarks: named points in the script, the time is a timestamp or
+	  milliseconds
 
 Movement range
 
@@ -85,6 +130,25 @@ Use the duration and distance to calculate the speed.
 Send the calculated position with the calculated speed at the time specified by
 the previous action.
 
+Multiple axes
+
+Scripts for other axes are either included in the axes list, or distributed
+as a set of files next to the stroke script, named after the axis:
+
+	video.funscript       : stroke (L0)
+	video.surge.funscript : surge (L1)
+	video.sway.funscript  : sway (L2)
+	video.twist.funscript : twist (R0)
+	video.roll.funscript  : roll (R1)
+	video.pitch.funscript : pitch (R2)
+	video.vib.funscript   : vibration (V0)
+
+The T-Code name can be used instead of the axis name (eg video.R1.funscript.)
+
+Other axes use the same movement algorithm as the stroke, with the default
+movement range and speed limits. They are not inverted and do not have an
+implicit starting position.
+
 Limitations
 
 The Launch obviously has it's speed limitations. There are two type of
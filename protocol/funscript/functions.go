@@ -10,7 +10,7 @@ import (
 func Speed(dist int, dur time.Duration) (speed int) {
 	if dist <= 0 {
 		return 0
-	} else if dist > 100 {
+	} else if dist > 100 || dur < time.Millisecond {
 		return 100
 	}
 	mil := float64(dur.Nanoseconds()/1e6) * 90 / float64(dist)
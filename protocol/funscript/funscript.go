@@ -2,6 +2,7 @@ package funscript
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/funjack/launchcontrol/protocol"
@@ -32,6 +33,18 @@ type Script struct {
 	Range Range `json:"range,omitempty"`
 	// Actions are the timed moves.
 	Actions []Action `json:"actions"`
+	// Axes are the timed moves of other axes than the stroke.
+	Axes []Axis `json:"axes,omitempty"`
+	// Metadata describes the script (optional.)
+	Metadata *protocol.Metadata `json:"metadata,omitempty"`
+}
+
+// Axis contains the timed moves of a named axis.
+type Axis struct {
+	// ID is the name of the axis (eg R0.)
+	ID string `json:"id"`
+	// Actions are the timed moves.
+	Actions []Action `json:"actions"`
 }
 
 // Action is a move at a specific time.
@@ -100,27 +113,43 @@ func (fs Script) TimedActions(minspd, maxspd, minpos, maxpos int) (s protocol.Ti
 	if maxpos > PositionMax {
 		maxpos = PositionMax
 	}
+	if maxpos < minpos {
+		maxpos = minpos
+	}
+	start := minpos // Init at bottom
+	if fs.Inverted {
+		start = maxpos // Init at top
+	}
+	return fs.timedActions(minspd, maxspd, minpos, maxpos,
+		Action{At: 0, Pos: 0}, start)
+}
+
+// timedActions creates the timed actions within the checked limits, starting
+// with a move to position from the action previous.
+func (fs Script) timedActions(minspd, maxspd, minpos, maxpos int, previous Action, position int) (s protocol.TimedActions, stat Stats) {
 	r := Range(maxpos - minpos)
-	if fs.Range != 0 && r > fs.Range {
+	if fs.Range > 0 && r > fs.Range {
 		r = fs.Range
 	}
+	// Actions are played in time order
+	actions := make([]Action, len(fs.Actions))
+	copy(actions, fs.Actions)
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].At < actions[j].At
+	})
 
 	s = make(protocol.TimedActions, 1, len(fs.Actions)+1)
 	s[0].Time = 0
-	if fs.Inverted {
-		s[0].Position = maxpos // Init at top
-	} else {
-
-		s[0].Position = minpos // Init at bottom
-	}
+	s[0].Position = position
 	s[0].Speed = SpeedLimitMin
 
 	previousPosition := s[0].Position
-	previous := Action{
-		At:  0,
-		Pos: 0,
-	}
-	for _, e := range fs.Actions {
+	for _, e := range actions {
+		if e.Pos < 0 {
+			e.Pos = 0
+		} else if e.Pos > 100 {
+			e.Pos = 100
+		}
 		if e.Pos == previous.Pos {
 			previous = e
 			continue
@@ -134,6 +163,9 @@ func (fs Script) TimedActions(minspd, maxspd, minpos, maxpos int) (s protocol.Ti
 			position = 100 - e.Pos
 		}
 		position = r.Position(position) + minpos
+		if position > maxpos {
+			position = maxpos
+		}
 		distance := position - previousPosition
 		if distance < 0 {
 			distance = -distance
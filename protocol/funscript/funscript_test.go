@@ -1,6 +1,9 @@
 package funscript
 
 import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
@@ -243,3 +246,71 @@ func TestStatsString(t *testing.T) {
 		t.Errorf("output does not match: want %q, got %q", want, got)
 	}
 }
+
+// checkPlayer fails when the actions of p break the invariants of a loaded
+// Funscript.
+func checkPlayer(t *testing.T, p protocol.Player) {
+	t.Helper()
+	ta, err := p.(protocol.Dumpable).Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Validate(); err != nil {
+		t.Fatalf("invalid actions: %v", err)
+	}
+	for i, a := range ta {
+		if a.Position < PositionMin || a.Position > PositionMax {
+			t.Fatalf("action %d: position %d out of limits", i, a.Position)
+		}
+		if a.Speed < SpeedLimitMin || a.Speed > SpeedLimitMax {
+			t.Fatalf("action %d: speed %d out of limits", i, a.Speed)
+		}
+	}
+}
+
+// randomActions returns actions that are mostly in order, with positions and
+// times out of range.
+func randomActions(r *rand.Rand) []Action {
+	var (
+		actions = make([]Action, r.Intn(50))
+		at      int64
+	)
+	for i := range actions {
+		switch r.Intn(20) {
+		case 0:
+			at = r.Int63() - r.Int63()
+		case 1:
+			at = 0
+		default:
+			at += r.Int63n(1000)
+		}
+		actions[i] = Action{At: at, Pos: r.Intn(300) - 100}
+	}
+	return actions
+}
+
+func TestLoadProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		s := Script{
+			Inverted: r.Intn(2) == 0,
+			Range:    Range(r.Intn(300) - 100),
+			Actions:  randomActions(r),
+		}
+		if r.Intn(4) == 0 {
+			s.Axes = []Axis{{ID: "R0", Actions: randomActions(r)}}
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l Loader
+		l.LimitSpeed(r.Intn(200)-50, r.Intn(200)-50)
+		l.LimitPosition(r.Intn(200)-50, r.Intn(200)-50)
+		p, err := l.Load(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		checkPlayer(t, p)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package funscript
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzLoad(f *testing.F) {
+	f.Add([]byte(script), 20, 80, 5, 95)
+	f.Add([]byte(multiAxisScript), 0, 1000, 0, 1000)
+	f.Add([]byte(`{"inverted":true,"range":50,"actions":[{"at":0,"pos":100}]}`), 1000, 0, 1000, 0)
+	f.Fuzz(func(t *testing.T, data []byte, slowest, fastest, lowest, highest int) {
+		var l Loader
+		l.LimitSpeed(slowest, fastest)
+		l.LimitPosition(lowest, highest)
+		p, err := l.Load(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkPlayer(t, p)
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/funjack/launchcontrol/protocol"
 )
@@ -73,10 +75,25 @@ func (l Loader) Load(r io.Reader) (protocol.Player, error) {
 	if err != nil {
 		return p, err
 	}
-	if len(s.Actions) == 0 {
+	if len(s.Actions) == 0 && len(s.Axes) == 0 {
 		return p, errors.New("empty script")
 	}
+	if err := checkTimes(s.Actions); err != nil {
+		return p, err
+	}
+	for _, a := range s.Axes {
+		if !isAxisID(a.ID) || a.ID == protocol.AxisStroke {
+			return p, fmt.Errorf("invalid axis %q", a.ID)
+		}
+		if err := checkTimes(a.Actions); err != nil {
+			return p, fmt.Errorf("axis %s: %v", a.ID, err)
+		}
+	}
 	log.Printf("Loading Funscript: %s", l)
+	if s.Metadata != nil && s.Metadata.Title != "" {
+		log.Printf("Funscript title: %s", s.Metadata.Title)
+	}
+	p.Meta = s.Metadata
 	var stats Stats
 	p.Script, stats = s.TimedActions(
 		l.speedMin,
@@ -84,5 +101,31 @@ func (l Loader) Load(r io.Reader) (protocol.Player, error) {
 		l.positionMin,
 		l.positionMax)
 	log.Printf("Funscript stats: %s", stats)
+	if len(s.Axes) == 0 {
+		return p, nil
+	}
+	for _, a := range s.Axes {
+		ta, stats := a.TimedActions()
+		log.Printf("Funscript %s stats: %s", a.ID, stats)
+		p.Script = append(p.Script, ta...)
+	}
+	// Play the actions of all axes in order
+	sort.SliceStable(p.Script, func(i, j int) bool {
+		return p.Script[i].Time < p.Script[j].Time
+	})
 	return p, nil
 }
+
+// maxTime is the latest action time (in milliseconds) that fits a Duration.
+const maxTime = int64(1<<63-1) / int64(time.Millisecond)
+
+// checkTimes returns an error when an action is not within the time that can
+// be played.
+func checkTimes(actions []Action) error {
+	for i, a := range actions {
+		if a.At < 0 || a.At > maxTime {
+			return fmt.Errorf("action %d: time %d out of range", i, a.At)
+		}
+	}
+	return nil
+}
@@ -2,8 +2,13 @@ package funscript
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
 )
 
 type LoaderTestCase struct {
@@ -69,3 +74,60 @@ func TestLoaderString(t *testing.T) {
 		t.Errorf("strings do not match, want %q, got %q", want, got)
 	}
 }
+
+func TestLoaderMetadata(t *testing.T) {
+	in := `{
+		"actions":[{"at":100,"pos":0},{"at":600,"pos":100}],
+		"metadata":{
+			"title":"Title",
+			"creator":"Creator",
+			"tags":["a","b"],
+			"performers":["c"],
+			"duration":600,
+			"script_url":"https://example.com/script",
+			"chapters":[
+				{"name":"Intro","startTime":"00:00:00.000","endTime":"00:00:00.500"}
+			]
+		}
+	}`
+	var l Loader
+	p, err := l.Load(bytes.NewBufferString(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &protocol.Metadata{
+		Title:      "Title",
+		Creator:    "Creator",
+		Tags:       []string{"a", "b"},
+		Performers: []string{"c"},
+		Duration:   600,
+		ScriptURL:  "https://example.com/script",
+		Chapters: []protocol.Chapter{{
+			Name: "Intro",
+			End:  time.Millisecond * 500,
+		}},
+	}
+	d, ok := p.(protocol.Describable)
+	if !ok {
+		t.Fatal("player is not describable")
+	}
+	if got := d.Metadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	// Round-trip through the export
+	dump, _ := p.(protocol.Dumpable).Dump()
+	s := FromTimedActions(dump)
+	s.Metadata = d.Metadata()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = l.Load(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(protocol.Describable).Metadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("round-trip: want %+v, got %+v", want, got)
+	}
+}
@@ -6,8 +6,21 @@ var (
 	limiterTime  = time.Millisecond * 151 // maximum event rate
 	upPosition   = 95                     // up position %
 	downPosition = 5                      // down position %
+	slowestSpeed = 20                     // slowest speed %
+	fastestSpeed = 99                     // fastest speed %
 )
 
+// DefaultAlgorithmName is the name of the DefaultAlgorithm in Algorithms.
+const DefaultAlgorithmName = "default"
+
+// Algorithms contains the registered algorithms by name.
+var Algorithms = map[string]Algorithm{
+	DefaultAlgorithmName: DefaultAlgorithm{},
+	"position":           PositionAlgorithm{},
+	"tempo":              TempoAlgorithm{},
+	"nolimit":            NoLimitAlgorithm{},
+}
+
 type togglePosition int
 
 func (a *togglePosition) Toggle() int {
@@ -20,3 +33,21 @@ func (a *togglePosition) Toggle() int {
 	*a = new
 	return int(*a)
 }
+
+// clampSpeed returns speed within the slowest and fastest speed.
+func clampSpeed(speed int) int {
+	if speed < slowestSpeed {
+		return slowestSpeed
+	} else if speed > fastestSpeed {
+		return fastestSpeed
+	}
+	return speed
+}
+
+// abs returns the absolute value of i.
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
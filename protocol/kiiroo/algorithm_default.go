@@ -12,56 +12,65 @@ type DefaultAlgorithm struct{}
 
 // Actions converts Kiiroo events into Actions that can be send to a Launch.
 func (da DefaultAlgorithm) Actions(es Events) []protocol.TimedAction {
-	var (
-		prevEvent           Event
-		prevAction          protocol.TimedAction
-		actionCount         int
-		position            togglePosition
-		speed, limitedSpeed int
-	)
+	var state defaultState
 
 	// count(actions) <= count(events)
-	actions := make([]protocol.TimedAction, len(es))
-
+	actions := make([]protocol.TimedAction, 0, len(es))
 	for _, e := range es {
-		// Move only when value is different from previous event
-		if e.Value == prevEvent.Value {
-			continue
+		if a, ok := state.Next(e); ok {
+			actions = append(actions, a)
 		}
+	}
+	return actions
+}
 
-		// Calculate speed for non-limited actions
-		speed = calcSpeed(e.Time-prevEvent.Time, speed)
+// defaultState holds the state of the DefaultAlgorithm between events so
+// events can be converted one at a time.
+type defaultState struct {
+	prevEvent           Event
+	prevAction          protocol.TimedAction
+	position            togglePosition
+	speed, limitedSpeed int
+}
 
-		// Event came in earlier than the limit allows
-		if e.Time-prevAction.Time <= limiterTime {
-			if limitedSpeed == 0 {
-				// Set the speed that will be used while lmiter
-				// is active
-				limitedSpeed = speed
-			}
-			// Only trigger if the last executed action longer ago
-			// than the current event.
-			if prevAction.Time < e.Time {
-				// Create a new event in at rate limit in the
-				// future.
-				actions[actionCount].Position = position.Toggle()
-				actions[actionCount].Speed = limitedSpeed
-				actions[actionCount].Time = prevAction.Time + limiterTime
-				prevAction = actions[actionCount]
-				actionCount++
-			}
-		} else {
-			limitedSpeed = 0 // Reset rate limit
-			actions[actionCount].Position = position.Toggle()
-			actions[actionCount].Speed = speed
-			actions[actionCount].Time = e.Time
-			prevAction = actions[actionCount]
-			actionCount++
-		}
+// Next converts the next event into an action, ok is false when the event
+// does not result in a move. The time of the action can be later than the
+// time of the event when the limiter is active.
+func (s *defaultState) Next(e Event) (a protocol.TimedAction, ok bool) {
+	// Move only when value is different from previous event
+	if e.Value == s.prevEvent.Value {
+		return a, false
+	}
+	defer func() { s.prevEvent = e }()
+
+	// Calculate speed for non-limited actions
+	s.speed = calcSpeed(e.Time-s.prevEvent.Time, s.speed)
 
-		prevEvent = e
+	// Event came in earlier than the limit allows
+	if e.Time-s.prevAction.Time <= limiterTime {
+		if s.limitedSpeed == 0 {
+			// Set the speed that will be used while lmiter is
+			// active
+			s.limitedSpeed = s.speed
+		}
+		// Only trigger if the last executed action longer ago than the
+		// current event.
+		if s.prevAction.Time < e.Time {
+			// Create a new event in at rate limit in the future.
+			a.Position = s.position.Toggle()
+			a.Speed = s.limitedSpeed
+			a.Time = s.prevAction.Time + limiterTime
+			s.prevAction = a
+			return a, true
+		}
+		return a, false
 	}
-	return actions[:actionCount]
+	s.limitedSpeed = 0 // Reset rate limit
+	a.Position = s.position.Toggle()
+	a.Speed = s.speed
+	a.Time = e.Time
+	s.prevAction = a
+	return a, true
 }
 
 // calcSpeed calculates the new speed based on the time difference and speed of
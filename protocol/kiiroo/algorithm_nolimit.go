@@ -0,0 +1,33 @@
+package kiiroo
+
+import "github.com/funjack/launchcontrol/protocol"
+
+// NoLimitAlgorithm implements the Algorithm interface like the
+// DefaultAlgorithm but without the limiter, every change of value results in
+// a move at the time of the event.
+type NoLimitAlgorithm struct{}
+
+// Actions converts Kiiroo events into Actions that can be send to a Launch.
+func (na NoLimitAlgorithm) Actions(es Events) []protocol.TimedAction {
+	var (
+		prevEvent Event
+		position  togglePosition
+		speed     int
+	)
+	actions := make([]protocol.TimedAction, 0, len(es))
+	for _, e := range es {
+		if e.Value == prevEvent.Value {
+			continue
+		}
+		speed = calcSpeed(e.Time-prevEvent.Time, speed)
+		actions = append(actions, protocol.TimedAction{
+			Action: protocol.Action{
+				Position: position.Toggle(),
+				Speed:    speed,
+			},
+			Time: e.Time,
+		})
+		prevEvent = e
+	}
+	return actions
+}
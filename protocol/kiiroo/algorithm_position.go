@@ -0,0 +1,63 @@
+package kiiroo
+
+import (
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// PositionAlgorithm implements the Algorithm interface using the value of
+// the events as position, 0 is the bottom and 4 is the top.
+type PositionAlgorithm struct{}
+
+// Actions converts Kiiroo events into Actions that move to the position of
+// the event value. The speed is such that the move finishes when the next
+// event starts. Events that come in faster than the limiter allows are
+// delayed, or skipped when a newer event is already due.
+func (pa PositionAlgorithm) Actions(es Events) []protocol.TimedAction {
+	actions := make([]protocol.TimedAction, 0, len(es))
+	prevPosition := -1
+	for i, e := range es {
+		position := valuePosition(e.Value)
+		if position == prevPosition {
+			continue
+		}
+
+		t := e.Time
+		if n := len(actions); n > 0 && t-actions[n-1].Time < limiterTime {
+			t = actions[n-1].Time + limiterTime
+			if i+1 < len(es) && es[i+1].Time <= t {
+				// Replaced by the next event
+				continue
+			}
+		}
+
+		// Time until the next event or a second for the last one
+		next := t + time.Second
+		if i+1 < len(es) && es[i+1].Time > t {
+			next = es[i+1].Time
+		}
+		speed := 50
+		if prevPosition >= 0 {
+			speed = clampSpeed(funscript.Speed(
+				abs(position-prevPosition), next-t))
+		}
+
+		actions = append(actions, protocol.TimedAction{
+			Action: protocol.Action{
+				Position: position,
+				Speed:    speed,
+			},
+			Time: t,
+		})
+		prevPosition = position
+	}
+	return actions
+}
+
+// valuePosition maps an event value (0-4) to a position between the down
+// and up position.
+func valuePosition(v int) int {
+	return downPosition + v*(upPosition-downPosition)/4
+}
@@ -0,0 +1,66 @@
+package kiiroo
+
+import (
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// TempoAlgorithm implements the Algorithm interface alternating between the
+// up and down position like the DefaultAlgorithm, but with a speed based on
+// the smoothed tempo of the events.
+type TempoAlgorithm struct{}
+
+// Actions converts Kiiroo events into Actions that can be send to a Launch.
+// The speed is such that a full stroke takes the moving average of the time
+// between events.
+func (ta TempoAlgorithm) Actions(es Events) []protocol.TimedAction {
+	var (
+		prevEvent  Event
+		position   togglePosition
+		interval   time.Duration // smoothed time between events
+		prevAction protocol.TimedAction
+		moved      bool
+	)
+	actions := make([]protocol.TimedAction, 0, len(es))
+	for _, e := range es {
+		// Move only when value is different from previous event
+		if e.Value == prevEvent.Value {
+			continue
+		}
+		dt := e.Time - prevEvent.Time
+		prevEvent = e
+
+		if dt < limiterTime {
+			dt = limiterTime
+		} else if dt > time.Second*2 {
+			dt = time.Second * 2
+		}
+		if interval == 0 || dt == time.Second*2 {
+			// Start over after a pause
+			interval = dt
+		} else {
+			interval = (interval*3 + dt) / 4
+		}
+
+		t := e.Time
+		if moved && t-prevAction.Time <= limiterTime {
+			if prevAction.Time >= e.Time {
+				continue
+			}
+			t = prevAction.Time + limiterTime
+		}
+		prevAction = protocol.TimedAction{
+			Action: protocol.Action{
+				Position: position.Toggle(),
+				Speed: clampSpeed(funscript.Speed(
+					upPosition-downPosition, interval)),
+			},
+			Time: t,
+		}
+		moved = true
+		actions = append(actions, prevAction)
+	}
+	return actions
+}
@@ -0,0 +1,119 @@
+package kiiroo
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestAlgorithmsGolden compares the actions of the algorithms for the badish
+// scenario with testdata/<algorithm>.golden.
+func TestAlgorithmsGolden(t *testing.T) {
+	var es Events
+	if err := es.UnmarshalText([]byte(scenario)); err != nil {
+		t.Fatal(err)
+	}
+	sort.Stable(es)
+
+	for name, alg := range Algorithms {
+		var buf bytes.Buffer
+		actions := alg.Actions(es)
+		for i, a := range actions {
+			fmt.Fprintf(&buf, "%d %d %d\n", a.Time/time.Millisecond,
+				a.Position, a.Speed)
+			if name == "nolimit" || i == 0 {
+				continue
+			}
+			if d := a.Time - actions[i-1].Time; d < limiterTime {
+				t.Errorf("%s: action %d after %s", name, i, d)
+			}
+		}
+
+		golden := filepath.Join("testdata", name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: want:\n%s\ngot:\n%s", name, want, buf.Bytes())
+		}
+	}
+}
+
+func TestSetAlgorithm(t *testing.T) {
+	p, err := playerwithscenario(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := p.(*ScriptPlayer)
+	def := len(sp.Script)
+	sp.SetAlgorithm(NoLimitAlgorithm{})
+	if len(sp.Script) <= def {
+		t.Errorf("script not converted again: %d <= %d actions",
+			len(sp.Script), def)
+	}
+}
+
+// checkActions returns an error when actions break the invariants of a
+// converted Kiiroo script.
+func checkActions(actions protocol.TimedActions) error {
+	if err := actions.Validate(); err != nil {
+		return err
+	}
+	for i, a := range actions {
+		if a.Position < downPosition || a.Position > upPosition {
+			return fmt.Errorf("action %d: position %d out of range", i,
+				a.Position)
+		}
+		if a.Speed < slowestSpeed {
+			return fmt.Errorf("action %d: speed %d too slow", i, a.Speed)
+		}
+	}
+	return nil
+}
+
+func TestAlgorithmsProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		var (
+			es = make(Events, r.Intn(100))
+			at time.Duration
+		)
+		for j := range es {
+			switch r.Intn(20) {
+			case 0:
+				at = time.Duration(r.Int63())
+			case 1:
+				// same time
+			default:
+				at += time.Duration(r.Int63n(int64(time.Second * 3)))
+			}
+			if at < 0 {
+				at = 0
+			}
+			es[j] = Event{Time: at, Value: r.Intn(5)}
+		}
+		sort.Stable(es)
+		for name, alg := range Algorithms {
+			if err := checkActions(alg.Actions(es)); err != nil {
+				t.Fatalf("%s: %v: %v", name, err, es)
+			}
+		}
+	}
+}
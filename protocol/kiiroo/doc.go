@@ -10,6 +10,11 @@ Protocol format is:
 	time : x.xx event time in sec
 	value: 0-4 position/intensity of event (but not for the Launch)
 
+Files found in the wild vary: the braces are optional, events can be
+separated by commas, semicolons or whitespace (also trailing), there can be
+whitespace around the colon and times can have any precision. Parse errors
+are reported as a SyntaxError with the line and column.
+
 Using the Kirroo protocol, the positions sends to the Launch are always 5% and
 95% (alternating.) Commands send to the Launch are interrupted/canceled by new
 ones, giving only the illusion of precision.
@@ -53,5 +58,36 @@ using last calculated speed. Reset the limiter when the receive window >150ms.
 The speed is still calculated when the limiter is active, but the last send
 speed is used until the limiter is stopped.
 
+Other algorithms
+
+Other algorithms are registered by name in Algorithms:
+
+	default : the algorithm described above
+	position: moves to the value as position, 0 is the bottom and 4 the top,
+	          at the speed that finishes the move when the next event starts
+	tempo   : moves up and down like the default algorithm, a full stroke
+	          takes the moving average of the time between events
+	nolimit : the default algorithm without the limiter
+
+VirtualRealPorn files
+
+VirtualRealPorn videos come with a .txt INI file containing the video
+information and tracks for one or more devices:
+
+	[VideoInfo]
+	name=<title>
+	studio=<creator>
+	actors=<performer>,...
+	categories=<tag>,...
+	duration=<seconds>
+
+	[Kiiroo]
+	onyx=<time>,<value>;<time>,<value>;...
+	titan=<time>,<value>;<time>,<value>;...
+
+The track for the Launch is picked in the order launch, onyx, titan. Tracks in
+the Kiiroo section go before tracks in other sections. The video information
+is kept as the metadata of the script.
+
 */
 package kiiroo
@@ -0,0 +1,92 @@
+//go:build go1.18
+// +build go1.18
+
+package kiiroo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func FuzzEvents(f *testing.F) {
+	f.Add([]byte(scenario))
+	f.Add([]byte("{ 1.23 : 2;\r\n1.50:4 }"))
+	f.Add([]byte("0.5:1 1:2,"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var es Events
+		if err := es.UnmarshalText(data); err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("want SyntaxError, got %v", err)
+			}
+			return
+		}
+		text, err := es.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again Events
+		if err := again.UnmarshalText(text); err != nil {
+			t.Fatalf("round trip %q: %v", text, err)
+		}
+		if len(again) != len(es) {
+			t.Fatalf("round trip %q: want %d events, got %d", text,
+				len(es), len(again))
+		}
+	})
+}
+
+func FuzzLoad(f *testing.F) {
+	f.Add([]byte(scenario))
+	f.Add([]byte("{0.00:1,9223372036.85:4}"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Load(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		sp := p.(*ScriptPlayer)
+		for name, alg := range Algorithms {
+			sp.SetAlgorithm(alg)
+			if err := checkActions(sp.Script); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+	})
+}
+
+func FuzzLoadText(f *testing.F) {
+	f.Add([]byte(vrpFile))
+	f.Add([]byte("[Kiiroo]\ronyx=1.00,4;2.50,1\r"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := LoadText(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkPlayer(t, p)
+	})
+}
+
+func FuzzLoadJSON(f *testing.F) {
+	f.Add([]byte(`{"text":"{1.00:4,2.50:1}"}`))
+	f.Add([]byte(`{"subs":{"text":" {1.00:4,2.50:1}"}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := LoadJSON(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkPlayer(t, p)
+	})
+}
+
+// checkPlayer fails when the script of p breaks the invariants.
+func checkPlayer(t *testing.T, p protocol.Player) {
+	t.Helper()
+	actions, err := p.(protocol.Dumpable).Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkActions(actions); err != nil {
+		t.Fatal(err)
+	}
+}
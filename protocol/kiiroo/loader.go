@@ -1,12 +1,10 @@
 package kiiroo
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"io"
 	"log"
-	"strings"
 
 	"github.com/funjack/launchcontrol/protocol"
 )
@@ -21,28 +19,23 @@ func Load(r io.Reader) (protocol.Player, error) {
 	return p, err
 }
 
-// LoadText loads a VRP txt file and returns a script player.
+// LoadText loads a VRP txt file and returns a script player for the track
+// that fits the Launch best.
 func LoadText(r io.Reader) (protocol.Player, error) {
-	var inKiirooBlock bool
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "[Kiiroo]" {
-			inKiirooBlock = true
-			continue
-		} else if len(line) > 0 && line[0] == '[' {
-			inKiirooBlock = false
-			continue
-		}
-
-		if inKiirooBlock && strings.HasPrefix(line, "onyx=") {
-			line = strings.TrimPrefix(line, "onyx=")
-			line = strings.Replace(line, ",", ":", -1)
-			line = strings.Replace(line, ";", ",", -1)
-			return Load(bytes.NewBufferString("{" + line + "}"))
-		}
+	v, err := ParseVRP(r)
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrEventFormat
+	name, es, ok := v.Track()
+	if !ok {
+		return nil, ErrNoEvents
+	}
+	p := NewScriptPlayer()
+	p.loadEvents(es)
+	p.Meta = v.Metadata
+	log.Printf("Kiiroo stats: %d actions (VRP track %s)", len(p.Script),
+		name)
+	return p, nil
 }
 
 // LoadJSON loads the FlMe JSON and returns a script player.
@@ -0,0 +1,214 @@
+package kiiroo
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSeconds is the largest event time in seconds that fits a Duration.
+const maxSeconds = int64(1<<63-1) / int64(time.Second)
+
+// SyntaxError is returned when Kiiroo events can not be parsed.
+type SyntaxError struct {
+	Line   int // line of the error, starting at 1
+	Column int // column of the error, starting at 1
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// tokenizer reads Kiiroo events from text, keeping track of the line and
+// column for errors.
+//
+// It accepts the variants found in the wild: a byte order mark, the braces
+// are optional, events are separated by commas, semicolons or whitespace
+// (trailing separators are ignored), there can be whitespace around the colon
+// and the time can have any precision.
+type tokenizer struct {
+	text         []byte
+	pos          int
+	line, column int
+}
+
+// parseEvents parses the events in text.
+func parseEvents(text []byte) (Events, error) {
+	text = bytes.TrimPrefix(text, []byte("\ufeff"))
+	t := &tokenizer{
+		text:   text,
+		line:   1,
+		column: 1,
+	}
+	return t.events()
+}
+
+// events reads all events.
+func (t *tokenizer) events() (Events, error) {
+	es := Events{}
+	t.skip(isSpace)
+	braces := t.peek() == '{'
+	if braces {
+		t.next()
+	}
+	for {
+		t.skip(isSeparator)
+		switch c := t.peek(); {
+		case c == 0 && t.pos >= len(t.text):
+			// Missing closing brace is tolerated
+			return es, nil
+		case c == '}':
+			if !braces {
+				return nil, t.errorf("unexpected '}'")
+			}
+			t.next()
+			t.skip(isSpace)
+			if t.pos < len(t.text) {
+				return nil, t.errorf("unexpected %q after '}'", t.peek())
+			}
+			return es, nil
+		}
+		e, err := t.event()
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+		if c := t.peek(); t.pos < len(t.text) && !isSeparator(c) && c != '}' {
+			return nil, t.errorf("unexpected %q after event", c)
+		}
+	}
+}
+
+// event reads a single <time>:<value> event.
+func (t *tokenizer) event() (Event, error) {
+	var e Event
+	line, column := t.line, t.column
+	s := t.scan(isTimeByte)
+	if s == "" {
+		return e, t.errorf("expected time, found %q", t.peek())
+	}
+	d, err := parseSeconds(s)
+	if err != nil {
+		return e, &SyntaxError{line, column, fmt.Sprintf("invalid time %q", s)}
+	}
+	t.skip(isSpace)
+	if t.peek() != ':' {
+		return e, t.errorf("expected ':' after time, found %q", t.peek())
+	}
+	t.next()
+	t.skip(isSpace)
+	line, column = t.line, t.column
+	s = t.scan(isDigit)
+	if s == "" {
+		return e, t.errorf("expected value, found %q", t.peek())
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v > 4 {
+		return e, &SyntaxError{line, column,
+			fmt.Sprintf("value %s out of range (0-4)", s)}
+	}
+	e.Time = d
+	e.Value = v
+	return e, nil
+}
+
+// peek returns the next byte without consuming it, or 0 at the end.
+func (t *tokenizer) peek() byte {
+	if t.pos >= len(t.text) {
+		return 0
+	}
+	return t.text[t.pos]
+}
+
+// next consumes a byte.
+func (t *tokenizer) next() {
+	c := t.text[t.pos]
+	t.pos++
+	// \r\n, \n and \r end a line
+	if c == '\n' || (c == '\r' && t.peek() != '\n') {
+		t.line++
+		t.column = 1
+		return
+	}
+	t.column++
+}
+
+// skip consumes bytes while f returns true.
+func (t *tokenizer) skip(f func(byte) bool) {
+	for t.pos < len(t.text) && f(t.text[t.pos]) {
+		t.next()
+	}
+}
+
+// scan consumes and returns bytes while f returns true.
+func (t *tokenizer) scan(f func(byte) bool) string {
+	start := t.pos
+	t.skip(f)
+	return string(t.text[start:t.pos])
+}
+
+// errorf returns a SyntaxError at the current position.
+func (t *tokenizer) errorf(format string, a ...interface{}) error {
+	return &SyntaxError{t.line, t.column, fmt.Sprintf(format, a...)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' ||
+		c == '\v'
+}
+
+func isSeparator(c byte) bool {
+	return c == ',' || c == ';' || isSpace(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isTimeByte(c byte) bool {
+	return isDigit(c) || c == '.'
+}
+
+// parseSeconds parses a decimal number of seconds without losing precision
+// (up to nanoseconds.)
+func parseSeconds(s string) (time.Duration, error) {
+	i := strings.IndexByte(s, '.')
+	whole, frac := s, ""
+	if i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, ErrEventFormat
+	}
+	var sec int64
+	if whole != "" {
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || n < 0 || n > maxSeconds {
+			return 0, ErrEventFormat
+		}
+		sec = n
+	}
+	var nsec int64
+	for j := 0; j < 9; j++ {
+		nsec *= 10
+		if j < len(frac) {
+			if !isDigit(frac[j]) {
+				return 0, ErrEventFormat
+			}
+			nsec += int64(frac[j] - '0')
+		}
+	}
+	for j := 9; j < len(frac); j++ {
+		if !isDigit(frac[j]) {
+			return 0, ErrEventFormat
+		}
+	}
+	d := time.Duration(sec)*time.Second + time.Duration(nsec)
+	if d < 0 {
+		return 0, ErrEventFormat
+	}
+	return d, nil
+}
@@ -0,0 +1,167 @@
+package kiiroo
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEventsVariants(t *testing.T) {
+	want := Events{
+		{Time: time.Millisecond * 1230, Value: 2},
+		{Time: time.Millisecond * 1500, Value: 4},
+		{Time: time.Millisecond * 3000, Value: 0},
+	}
+	cases := []string{
+		"{1.23:2,1.50:4,3.00:0}",
+		"1.23:2,1.50:4,3.00:0",
+		"{1.23:2,1.50:4,3.00:0,}",
+		"{ 1.23 : 2, 1.50 :4 ,3:0 }",
+		"{1.23:2;1.50:4;3.00:0;}",
+		"\ufeff{\r\n  1.23:2,\r\n  1.50:4,\r\n  3.00:0\r\n}\r\n",
+		"1.23:2 1.50:4\t3.000:0",
+		"{1.23:2,,1.5:4,3.:0",
+	}
+	for _, c := range cases {
+		var es Events
+		if err := es.UnmarshalText([]byte(c)); err != nil {
+			t.Errorf("%q: %v", c, err)
+			continue
+		}
+		if !reflect.DeepEqual(es, want) {
+			t.Errorf("%q: want %v, got %v", c, want, es)
+		}
+	}
+}
+
+func TestParseEventsPrecision(t *testing.T) {
+	var es Events
+	if err := es.UnmarshalText([]byte("{8.12:1,0.0015:2,.5:3,1.1234567899:4}")); err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{
+		time.Millisecond * 8120,
+		time.Microsecond * 1500,
+		time.Millisecond * 500,
+		time.Nanosecond * 1123456789,
+	}
+	for i, w := range want {
+		if es[i].Time != w {
+			t.Errorf("event %d: want %s, got %s", i, w, es[i].Time)
+		}
+	}
+}
+
+func TestParseEventsEmpty(t *testing.T) {
+	for _, c := range []string{"", "{}", " { } ", "{,}"} {
+		var es Events
+		if err := es.UnmarshalText([]byte(c)); err != nil {
+			t.Errorf("%q: %v", c, err)
+		}
+		if len(es) != 0 {
+			t.Errorf("%q: want no events, got %v", c, es)
+		}
+	}
+}
+
+func TestParseEventsErrors(t *testing.T) {
+	cases := []struct {
+		Text         string
+		Line, Column int
+	}{
+		{"{", 0, 0}, // tolerated
+		{"}", 1, 1},
+		{"{1.00:1}x", 1, 9},
+		{"{1.00:1,2.00}", 1, 13},
+		{"{1.00:1,2.00:}", 1, 14},
+		{"{1.00:1,\n2.00:5}", 2, 6},
+		{"{1.00:1,\r\n  x:1}", 2, 3},
+		{"{1.00:1\r2.0.0:1}", 2, 1},
+		{"{1.00:1a}", 1, 8},
+		{"{99999999999999999999:1}", 1, 2},
+		{"{1.00:-1}", 1, 7},
+	}
+	for _, c := range cases {
+		var es Events
+		err := es.UnmarshalText([]byte(c.Text))
+		if c.Line == 0 {
+			if err != nil {
+				t.Errorf("%q: %v", c.Text, err)
+			}
+			continue
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: want SyntaxError, got %v", c.Text, err)
+			continue
+		}
+		if serr.Line != c.Line || serr.Column != c.Column {
+			t.Errorf("%q: want error at %d:%d, got %v", c.Text, c.Line,
+				c.Column, serr)
+		}
+	}
+}
+
+func TestEventUnmarshalTextErrors(t *testing.T) {
+	for _, c := range []string{"", ":", "1.00", "1.00:", ":1", "1.00:1,", "1.00:1:2", "1.00:5"} {
+		var e Event
+		if err := e.UnmarshalText([]byte(c)); err != ErrEventFormat {
+			t.Errorf("%q: want ErrEventFormat, got %v", c, err)
+		}
+	}
+	var e Event
+	if err := e.UnmarshalText([]byte(" 1.00 : 1 ")); err != nil {
+		t.Errorf("whitespace: %v", err)
+	}
+}
+
+// TestParseEventsRandom feeds mutations of valid scripts to the parser. It
+// must not panic, and what it accepts must survive a round trip. FuzzEvents
+// does the same with the fuzzer of Go 1.18 and later.
+func TestParseEventsRandom(t *testing.T) {
+	seeds := []string{
+		"{1.23:2,1.50:4,3.00:0}",
+		"{0.0015:1,1.1234567899:2}",
+		"{ 1.23 : 2;\r\n1.50:4 }",
+		"0.5:1 1:2",
+	}
+	alphabet := []byte("{}:;,. \r\n0123456789")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		data := []byte(seeds[r.Intn(len(seeds))])
+		for n := r.Intn(8); n >= 0; n-- {
+			pos := r.Intn(len(data) + 1)
+			switch r.Intn(3) {
+			case 0: // insert
+				c := alphabet[r.Intn(len(alphabet))]
+				data = append(data[:pos], append([]byte{c}, data[pos:]...)...)
+			case 1: // delete
+				if pos < len(data) {
+					data = append(data[:pos], data[pos+1:]...)
+				}
+			case 2: // truncate
+				data = data[:pos]
+			}
+		}
+		var es Events
+		if err := es.UnmarshalText(data); err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("%q: want SyntaxError, got %v", data, err)
+			}
+			continue
+		}
+		text, err := es.MarshalText()
+		if err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		var again Events
+		if err := again.UnmarshalText(text); err != nil {
+			t.Fatalf("%q: round trip %q: %v", data, text, err)
+		}
+		if !reflect.DeepEqual(again, es) {
+			t.Fatalf("%q: round trip %q: want %v, got %v", data,
+				text, es, again)
+		}
+	}
+}
@@ -12,14 +12,15 @@ import (
 type ScriptPlayer struct {
 	*protocol.TimedActionsPlayer
 
-	alg Algorithm
+	alg    Algorithm
+	events Events
 }
 
 // NewScriptPlayer returns a new ScriptPlayer using the default algorithm.
 func NewScriptPlayer() *ScriptPlayer {
 	return &ScriptPlayer{
-		protocol.NewTimedActionsPlayer(),
-		DefaultAlgorithm{},
+		TimedActionsPlayer: protocol.NewTimedActionsPlayer(),
+		alg:                DefaultAlgorithm{},
 	}
 }
 
@@ -33,8 +34,25 @@ func (k *ScriptPlayer) Load(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	sort.Sort(es)
+	if len(es) == 0 {
+		return ErrNoEvents
+	}
+	k.loadEvents(es)
+	return nil
+}
 
+// SetAlgorithm changes the algorithm that converts the events into actions,
+// the loaded script is converted again.
+func (k *ScriptPlayer) SetAlgorithm(a Algorithm) {
+	k.alg = a
+	if k.events != nil {
+		k.Script = k.alg.Actions(k.events)
+	}
+}
+
+// loadEvents creates the script from the events.
+func (k *ScriptPlayer) loadEvents(es Events) {
+	sort.Stable(es)
+	k.events = es
 	k.Script = k.alg.Actions(es)
-	return nil
 }
@@ -0,0 +1,274 @@
+package kiiroo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// ErrNotStreaming is returned when an event is pushed to a stream that has
+// been stopped.
+var ErrNotStreaming = errors.New("stream is not playing")
+
+// StreamPlayer plays Kiiroo events as they arrive, like the values send by
+// interactive and webcam sessions. Events are converted with the same rules
+// as the DefaultAlgorithm, one at a time, using the time they are pushed.
+type StreamPlayer struct {
+	sync.Mutex
+
+	latency  time.Duration
+	posLimit func(int) int
+	spdLimit func(int) int
+	posLow   int
+	posHigh  int
+	inverted bool
+
+	events  chan Event
+	stop    chan struct{}
+	stopped bool
+	start   time.Time // time of the first event or playback start
+	wg      sync.WaitGroup
+}
+
+// NewStreamPlayer returns a new StreamPlayer.
+func NewStreamPlayer() *StreamPlayer {
+	return &StreamPlayer{
+		posLimit: func(p int) int { return p },
+		spdLimit: func(s int) int { return s },
+		posHigh:  100,
+		events:   make(chan Event, 16),
+	}
+}
+
+// Play starts playing the events, including the events pushed before
+// playback started. Playback continues until Stop is called.
+func (p *StreamPlayer) Play() <-chan protocol.Action {
+	// Only play one stream at a time
+	p.wg.Wait()
+	p.wg.Add(1)
+
+	p.Lock()
+	defer p.Unlock()
+	out := make(chan protocol.Action)
+	p.stop = make(chan struct{})
+	p.stopped = false
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	go p.playbackLoop(out, p.events, p.stop)
+	return out
+}
+
+// Stop stops playback.
+func (p *StreamPlayer) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.stop == nil {
+		return nil
+	}
+	close(p.stop)
+	p.stop = nil
+	p.stopped = true
+	p.start = time.Time{}
+	p.events = make(chan Event, 16) // discard pending events
+	return nil
+}
+
+// Push adds an event with value (0-4) at the current time. Events pushed
+// before Play are played when playback starts.
+func (p *StreamPlayer) Push(value int) error {
+	if value < 0 || value > 4 {
+		return ErrEventFormat
+	}
+	p.Lock()
+	if p.stopped {
+		p.Unlock()
+		return ErrNotStreaming
+	}
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	events, stop := p.events, p.stop
+	e := Event{Time: time.Since(p.start), Value: value}
+	p.Unlock()
+	select {
+	case events <- e:
+		return nil
+	case <-stop:
+		return ErrNotStreaming
+	case <-time.After(limiterTime):
+		return protocol.ErrTimeout
+	}
+}
+
+// Latency implements the LatencyCalibrator interface, all actions are delayed
+// by t.
+func (p *StreamPlayer) Latency(t time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+	p.latency = t
+}
+
+// LimitPosition implements the PositionLimiter interface.
+func (p *StreamPlayer) LimitPosition(low, high int) {
+	if low >= high {
+		// Ignore invalid config
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.posLow, p.posHigh = low, high
+	p.posLimit = func(v int) int {
+		if v < low {
+			return low
+		}
+		if v > high {
+			return high
+		}
+		return v
+	}
+}
+
+// Invert implements the Inverter interface, positions are mirrored within the
+// position limits.
+func (p *StreamPlayer) Invert(inverted bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.inverted = inverted
+}
+
+// LimitSpeed implements the SpeedLimiter interface.
+func (p *StreamPlayer) LimitSpeed(slow, fast int) {
+	if slow >= fast {
+		// Ignore invalid config
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.spdLimit = func(v int) int {
+		if v < slow {
+			return slow
+		}
+		if v > fast {
+			return fast
+		}
+		return v
+	}
+}
+
+// playbackLoop converts the events into actions and sends them to out at
+// their time.
+func (p *StreamPlayer) playbackLoop(out chan<- protocol.Action, events <-chan Event, stop <-chan struct{}) {
+	defer func() {
+		p.wg.Done()
+		close(out)
+	}()
+
+	var (
+		state   defaultState
+		pending []protocol.TimedAction // ordered by time
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		select {
+		case <-stop:
+			return
+		case e := <-events:
+			if a, ok := state.Next(e); ok {
+				pending = append(pending, a)
+			}
+		case <-timer.C:
+		}
+
+		p.Lock()
+		latency := p.latency
+		start := p.start
+		p.Unlock()
+
+		// Send all actions that are due and wait for the next one.
+		for len(pending) > 0 {
+			wait := pending[0].Time + latency - time.Since(start)
+			if wait > 0 {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(wait)
+				break
+			}
+			a := pending[0].Action
+			pending = pending[1:]
+			p.Lock()
+			a.Position = p.posLimit(a.Position)
+			if p.inverted {
+				a.Position = p.posLow + p.posHigh - a.Position
+			}
+			a.Speed = p.spdLimit(a.Speed)
+			p.Unlock()
+			select {
+			case out <- a:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// PushStream reads Kiiroo events from r and pushes them to p as they arrive.
+// Events are separated by whitespace, commas or semicolons and are either a
+// value (0-4) or a time:value pair of which only the value is used. Braces
+// around the events are ignored. It returns when r is closed or an invalid
+// event is read.
+func PushStream(r io.Reader, p *StreamPlayer) error {
+	s := bufio.NewScanner(r)
+	s.Split(scanEvents)
+	for s.Scan() {
+		token := s.Bytes()
+		if i := bytes.IndexByte(token, ':'); i >= 0 {
+			token = token[i+1:]
+		}
+		v, err := strconv.Atoi(string(token))
+		if err != nil {
+			return ErrEventFormat
+		}
+		if err := p.Push(v); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// scanEvents is a bufio.SplitFunc returning the events in a stream.
+func scanEvents(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	isSep := func(b byte) bool {
+		switch b {
+		case ' ', '\t', '\r', '\n', ',', ';', '{', '}':
+			return true
+		}
+		return false
+	}
+	start := 0
+	for start < len(data) && isSep(data[start]) {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if isSep(data[i]) {
+			return i + 1, data[start:i], nil
+		}
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
@@ -0,0 +1,144 @@
+package kiiroo
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func TestStreamPlayer(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("don't run timing tests on darwin #17610")
+	}
+
+	p := NewStreamPlayer()
+	out := p.Play()
+
+	type timedAction struct {
+		protocol.Action
+		Time time.Duration
+	}
+	starttime := time.Now()
+	received := make(chan []timedAction)
+	go func() {
+		var as []timedAction
+		for a := range out {
+			as = append(as, timedAction{a, time.Since(starttime)})
+		}
+		received <- as
+	}()
+
+	// Values pushed faster than the limiter allows are delayed or
+	// dropped like with the DefaultAlgorithm.
+	pushes := []struct {
+		Value int
+		Wait  time.Duration
+	}{
+		{1, time.Millisecond * 50},  // delayed till the limiter time
+		{4, time.Millisecond * 350}, // dropped
+		{2, time.Millisecond * 20},
+		{3, time.Millisecond * 300}, // delayed
+	}
+	for _, push := range pushes {
+		if err := p.Push(push.Value); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(push.Wait)
+	}
+	if err := p.Push(5); err != ErrEventFormat {
+		t.Errorf("push invalid value: want ErrEventFormat, got %v", err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	actions := <-received
+
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions, got %d: %v", len(actions), actions)
+	}
+	av := actionValidator{}
+	for _, a := range actions {
+		t.Logf("Action: %s: %d,%d", a.Time, a.Position, a.Speed)
+		// Allow some timer jitter
+		if err := av.Validate(a.Position, a.Time+time.Millisecond*5); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := p.Push(1); err != ErrNotStreaming {
+		t.Errorf("push after stop: want ErrNotStreaming, got %v", err)
+	}
+}
+
+func TestScanEvents(t *testing.T) {
+	s := bufio.NewScanner(bytes.NewBufferString("{1.00:1,1.50:4} 2;3\n\t4"))
+	s.Split(scanEvents)
+	var tokens []string
+	for s.Scan() {
+		tokens = append(tokens, s.Text())
+	}
+	want := []string{"1.00:1", "1.50:4", "2", "3", "4"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("want tokens %q, got %q", want, tokens)
+	}
+}
+
+func TestPushStream(t *testing.T) {
+	cases := []struct {
+		Stream string
+		Err    error
+	}{
+		{"{1.00:1,1.50:4}", nil},
+		{"1 4;2\n3\t", nil},
+		{"1,x,4", ErrEventFormat},
+		{"1.00:9", ErrEventFormat},
+	}
+	for _, c := range cases {
+		p := NewStreamPlayer()
+		out := p.Play()
+		done := make(chan int)
+		go func() {
+			var n int
+			for range out {
+				n++
+			}
+			done <- n
+		}()
+		if err := PushStream(bytes.NewBufferString(c.Stream), p); err != c.Err {
+			t.Errorf("%q: want error %v, got %v", c.Stream, c.Err, err)
+		}
+		// Wait for the delayed first action
+		time.Sleep(limiterTime * 2)
+		p.Stop()
+		if n := <-done; c.Err == nil && n != 1 {
+			t.Errorf("%q: want 1 action, got %d", c.Stream, n)
+		}
+	}
+
+	// Events pushed before playback starts are played
+	p := NewStreamPlayer()
+	if err := PushStream(bytes.NewBufferString("1"), p); err != nil {
+		t.Fatal(err)
+	}
+	out := p.Play()
+	done := make(chan int)
+	go func() {
+		var n int
+		for range out {
+			n++
+		}
+		done <- n
+	}()
+	time.Sleep(limiterTime * 2)
+	p.Stop()
+	if n := <-done; n != 1 {
+		t.Errorf("event pushed before play: want 1 action, got %d", n)
+	}
+	if err := PushStream(bytes.NewBufferString("1"), p); err != ErrNotStreaming {
+		t.Errorf("push to stopped player: want ErrNotStreaming, got %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package kiiroo
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -28,31 +27,39 @@ type Event struct {
 	Value int
 }
 
-// MarshalText implements the encoding.TextMarshaler interface.
+// MarshalText implements the encoding.TextMarshaler interface. The time is
+// written with at least two decimals, and as many as needed to keep it exact.
 func (e Event) MarshalText() (text []byte, err error) {
-	text = []byte(fmt.Sprintf("%.2f:%d", e.Time.Seconds(), e.Value))
+	t, sign := e.Time, ""
+	if t < 0 {
+		t, sign = -t, "-"
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%09d", t%time.Second), "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	text = []byte(fmt.Sprintf("%s%d.%s:%d", sign, t/time.Second, frac,
+		e.Value))
 	return text, err
 }
 
 // UnmarshalText implements the encoding.TextUnmarshaler interface.
 func (e *Event) UnmarshalText(text []byte) error {
-	s := strings.Split(string(text), ":")
-	if len(s) != 2 {
-		return ErrEventFormat
+	t := &tokenizer{
+		text:   text,
+		line:   1,
+		column: 1,
 	}
-	t, err := strconv.ParseFloat(s[0], 64)
+	t.skip(isSpace)
+	event, err := t.event()
 	if err != nil {
 		return ErrEventFormat
 	}
-	timestamp := time.Duration(int64(t*1000)) * time.Millisecond
-	value, err := strconv.ParseInt(s[1], 10, 16)
-	if err != nil || value < 0 || value > 4 {
+	t.skip(isSpace)
+	if t.pos < len(t.text) {
 		return ErrEventFormat
 	}
-	*e = Event{
-		Time:  timestamp,
-		Value: int(value),
-	}
+	*e = event
 	return nil
 }
 
@@ -88,19 +95,12 @@ func (es Events) MarshalText() (text []byte, err error) {
 	return []byte("{" + strings.Join(values, ",") + "}"), nil
 }
 
-// UnmarshalText implements the encoding.TextUnmarshaler interface.
+// UnmarshalText implements the encoding.TextUnmarshaler interface. Errors
+// in text are returned as a *SyntaxError.
 func (es *Events) UnmarshalText(text []byte) error {
-	t := strings.TrimSpace(string(text))
-	size := strings.Count(t, ",")
-	var events = make([]Event, size+1)
-
-	for i, s := range strings.Split(t[1:len(t)-1], ",") {
-		e := new(Event)
-		err := e.UnmarshalText([]byte(s))
-		if err != nil {
-			return err
-		}
-		events[i] = *e
+	events, err := parseEvents(text)
+	if err != nil {
+		return err
 	}
 	*es = events
 	return nil
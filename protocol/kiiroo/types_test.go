@@ -7,19 +7,22 @@ import (
 )
 
 func TestEventMarshalText(t *testing.T) {
-	want := "1.23:2"
-	e := Event{
-		Time:  time.Millisecond * 1234,
-		Value: 2,
-	}
-
-	v, err := e.MarshalText()
-	if err != nil {
-		t.Error(err)
-	}
-
-	if want != string(v) {
-		t.Errorf("want: %q, got %q", want, v)
+	cases := map[time.Duration]string{
+		time.Millisecond * 1234:   "1.234:2",
+		time.Millisecond * 1500:   "1.50:2",
+		time.Second * 3:           "3.00:2",
+		time.Microsecond * 1500:   "0.0015:2",
+		time.Nanosecond * 1e9 / 3: "0.333333333:2",
+		-time.Millisecond * 1230:  "-1.23:2",
+	}
+	for d, want := range cases {
+		v, err := Event{Time: d, Value: 2}.MarshalText()
+		if err != nil {
+			t.Error(err)
+		}
+		if want != string(v) {
+			t.Errorf("%s: want: %q, got %q", d, want, v)
+		}
 	}
 }
 
@@ -0,0 +1,177 @@
+package kiiroo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+// vrpTracks are the names of the device tracks in a VRP file, in order of
+// preference for the Launch.
+var vrpTracks = []string{"launch", "onyx", "titan"}
+
+// VRP is a VirtualRealPorn sidecar file (.txt) with the video information
+// and Kiiroo tracks for one or more devices.
+type VRP struct {
+	Metadata *protocol.Metadata // nil without video information
+	Tracks   map[string]Events  // events by (lowercase) device name
+}
+
+// ParseVRP reads a VRP file. The file is an INI file with a [VideoInfo]
+// section and device tracks (eg onyx=1.00,4;2.50,1) in the [Kiiroo] or
+// other sections.
+func ParseVRP(r io.Reader) (*VRP, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+	v := &VRP{
+		Metadata: vrpMetadata(sections["videoinfo"]),
+		Tracks:   make(map[string]Events),
+	}
+	// Tracks in the Kiiroo section go before those in other sections.
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "kiiroo") != (names[j] == "kiiroo") {
+			return names[i] == "kiiroo"
+		}
+		return names[i] < names[j]
+	})
+	for _, section := range names {
+		for _, name := range vrpTracks {
+			value, ok := sections[section][name]
+			if !ok {
+				continue
+			}
+			if _, dup := v.Tracks[name]; dup {
+				continue
+			}
+			es, err := parseTrack(value)
+			if err != nil {
+				return nil, err
+			}
+			if len(es) > 0 {
+				v.Tracks[name] = es
+			}
+		}
+	}
+	return v, nil
+}
+
+// Track returns the name and events of the track that fits the Launch best.
+// The last return value is false if there are no tracks.
+func (v *VRP) Track() (string, Events, bool) {
+	for _, name := range vrpTracks {
+		if es, ok := v.Tracks[name]; ok {
+			return name, es, true
+		}
+	}
+	return "", nil, false
+}
+
+// parseINI returns the key value pairs by section of an INI file. Section
+// names and keys are lowercase. Lines that are not sections or key value
+// pairs, and comments (; or #) are ignored.
+func parseINI(r io.Reader) (map[string]map[string]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Some files use carriage returns as line endings.
+	data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+
+	var (
+		sections = make(map[string]map[string]string)
+		section  = make(map[string]string)
+	)
+	sections[""] = section
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", line[0] == ';', line[0] == '#':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			name := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if _, ok := sections[name]; !ok {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		section[key] = strings.TrimSpace(line[i+1:])
+	}
+	return sections, scanner.Err()
+}
+
+// parseTrack parses the events of a device track (<time>,<value>;...).
+func parseTrack(s string) (Events, error) {
+	var es Events
+	for _, v := range strings.Split(s, ";") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		var e Event
+		err := e.UnmarshalText([]byte(strings.Replace(v, ",", ":", 1)))
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+	}
+	sort.Sort(es)
+	return es, nil
+}
+
+// vrpMetadata returns the metadata in the [VideoInfo] section, or nil if
+// there is none.
+func vrpMetadata(info map[string]string) *protocol.Metadata {
+	var m protocol.Metadata
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := info[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	list := func(keys ...string) []string {
+		var l []string
+		for _, v := range strings.Split(first(keys...), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				l = append(l, v)
+			}
+		}
+		return l
+	}
+	m.Title = first("name", "title")
+	m.Description = first("description")
+	m.Creator = first("studio", "creator", "author")
+	m.Tags = list("tags", "categories")
+	m.Performers = list("actors", "performers", "models")
+	m.VideoURL = first("url")
+	d, err := strconv.ParseFloat(first("duration", "length"), 64)
+	if err == nil && d > 0 {
+		m.Duration = int(d)
+	}
+	if reflect.DeepEqual(m, protocol.Metadata{}) {
+		return nil
+	}
+	return &m
+}
@@ -0,0 +1,95 @@
+package kiiroo
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+var vrpFile = "; VirtualRealPorn\r\n" + `[Player]
+zoom=0
+
+[VideoInfo]
+Name=Test Video
+Studio=VRP
+Actors=Alice, Bob
+Categories=pov,vr
+Duration=1830.5
+version=2
+
+[Kiiroo]
+titan=1.00,2;2.00,3
+onyx=2.50,1;1.00,4;8.25,3
+
+[Launch]
+onyx=9.00,1
+`
+
+func TestParseVRP(t *testing.T) {
+	v, err := ParseVRP(bytes.NewBufferString(vrpFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMeta := &protocol.Metadata{
+		Title:      "Test Video",
+		Creator:    "VRP",
+		Performers: []string{"Alice", "Bob"},
+		Tags:       []string{"pov", "vr"},
+		Duration:   1830,
+	}
+	if !reflect.DeepEqual(v.Metadata, wantMeta) {
+		t.Errorf("want metadata %+v, got %+v", wantMeta, v.Metadata)
+	}
+
+	// The onyx track of the Kiiroo section, sorted.
+	name, es, ok := v.Track()
+	wantEvents := Events{
+		{Time: time.Second, Value: 4},
+		{Time: time.Millisecond * 2500, Value: 1},
+		{Time: time.Millisecond * 8250, Value: 3},
+	}
+	if !ok || name != "onyx" || !reflect.DeepEqual(es, wantEvents) {
+		t.Errorf("want onyx %v, got %s %v", wantEvents, name, es)
+	}
+	if len(v.Tracks["titan"]) != 2 {
+		t.Errorf("titan track missing: %v", v.Tracks)
+	}
+
+	// The launch track is preferred.
+	v, err = ParseVRP(bytes.NewBufferString(vrpFile + "launch=1.00,1;2.00,4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _, _ := v.Track(); name != "launch" {
+		t.Errorf("want launch track, got %s", name)
+	}
+
+	v, err = ParseVRP(bytes.NewBufferString("[Player]\rzoom=0\r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := v.Track(); ok || v.Metadata != nil {
+		t.Errorf("found tracks or metadata in empty file: %+v", v)
+	}
+
+	if _, err := ParseVRP(bytes.NewBufferString("[Kiiroo]\nonyx=1.00,9\n")); err != ErrEventFormat {
+		t.Errorf("invalid value: want ErrEventFormat, got %v", err)
+	}
+}
+
+func TestLoadTextMetadata(t *testing.T) {
+	p, err := LoadText(bytes.NewBufferString(vrpFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := p.(protocol.Describable).Metadata()
+	if m == nil || m.Title != "Test Video" {
+		t.Errorf("metadata not loaded: %+v", m)
+	}
+	if _, err := LoadText(bytes.NewBufferString("[Player]\nzoom=0\n")); err != ErrNoEvents {
+		t.Errorf("no tracks: want ErrNoEvents, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Package live plays positions that are pushed in realtime.
+
+Interactive apps and games can control the Launch by sending positions as they
+happen instead of uploading a script. Every message is a JSON encoded object,
+multiple messages can be separated by newlines:
+
+  {
+    "pos": <position>,
+    "at": <time>,
+    "spd": <speed>
+  }
+
+  position: integer, position to move to in percent 0-100 (bottom ... top)
+  time    : integer, time in ms of the position on the senders clock
+  speed   : integer, speed to move at 20-99 (slow ... fast) (optional)
+
+Messages without a speed are timed positions like the actions in a Funscript,
+the speed is calculated from the distance and time since the previous
+position, and the position is scaled within the position limits. A time that
+is not after the previous time starts over and moves at the slowest speed.
+
+Messages with a speed are raw moves like in the raw format, the position and
+speed are used as is but kept within the position and speed limits.
+
+Positions are moved to as soon as they arrive, but not more often than the
+minimum time between actions of Funscripts. Positions that arrive faster are
+merged and only the latest position is moved to.
+*/
+package live
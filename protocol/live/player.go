@@ -0,0 +1,305 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+var (
+	// ErrNotStreaming is returned when a message is pushed to a player
+	// that has been stopped.
+	ErrNotStreaming = errors.New("live player is not playing")
+	// ErrInvalidMessage is returned when a message can not be decoded or
+	// has values out of range.
+	ErrInvalidMessage = errors.New("invalid live message")
+)
+
+// Message is a position pushed in realtime.
+type Message struct {
+	// Position in percent to move to.
+	Position int `json:"pos"`
+	// Time in milliseconds of the position on the senders clock.
+	Time int64 `json:"at,omitempty"`
+	// Speed to move at, 0 calculates the speed from the time.
+	Speed int `json:"spd,omitempty"`
+}
+
+// Validate checks if the values of the message are in range.
+func (m Message) Validate() error {
+	if m.Position < 0 || m.Position > 100 || m.Time < 0 ||
+		m.Speed < 0 || m.Speed > 100 {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// move is the last position that was moved to.
+type move struct {
+	time     int64
+	position int
+	timed    bool
+}
+
+// Player moves to the positions pushed to it as they arrive.
+type Player struct {
+	sync.Mutex
+
+	positionMin int
+	positionMax int
+	speedMin    int
+	speedMax    int
+	inverted    bool
+
+	next    *Message // latest message that has not been moved to
+	notify  chan struct{}
+	stop    chan struct{}
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// NewPlayer returns a new Player using the Funscript limits.
+func NewPlayer() *Player {
+	return &Player{
+		positionMin: funscript.PositionMin,
+		positionMax: funscript.PositionMax,
+		speedMin:    funscript.SpeedLimitMin,
+		speedMax:    funscript.SpeedLimitMax,
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// Play starts moving to the pushed positions, including the position pushed
+// before playback started. Playback continues until Stop is called.
+func (p *Player) Play() <-chan protocol.Action {
+	// Only play one stream at a time
+	p.wg.Wait()
+	p.wg.Add(1)
+
+	p.Lock()
+	defer p.Unlock()
+	out := make(chan protocol.Action)
+	p.stop = make(chan struct{})
+	p.stopped = false
+	go p.playbackLoop(out, p.notify, p.stop)
+	return out
+}
+
+// Stop stops playback.
+func (p *Player) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.stop == nil {
+		return nil
+	}
+	close(p.stop)
+	p.stop = nil
+	p.stopped = true
+	p.next = nil
+	return nil
+}
+
+// Push moves to the position in m. It replaces the previous message when
+// that has not been moved to yet.
+func (p *Player) Push(m Message) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+	if p.stopped {
+		return ErrNotStreaming
+	}
+	p.next = &m
+	select {
+	case p.notify <- struct{}{}:
+	default:
+		// Already notified
+	}
+	return nil
+}
+
+// LimitPosition implements the PositionLimiter interface.
+func (p *Player) LimitPosition(lowest, highest int) {
+	p.Lock()
+	defer p.Unlock()
+	switch min := lowest; true {
+	case min < 0:
+		p.positionMin = 0
+	case min > 90:
+		p.positionMin = 90
+	default:
+		p.positionMin = min
+	}
+	switch max := highest; true {
+	case max > 100:
+		p.positionMax = 100
+	case max < 10:
+		p.positionMax = 10
+	default:
+		p.positionMax = max
+	}
+}
+
+// Invert implements the Inverter interface, pushed positions are mirrored.
+func (p *Player) Invert(inverted bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.inverted = inverted
+}
+
+// LimitSpeed implements the SpeedLimiter interface.
+func (p *Player) LimitSpeed(slowest, fastest int) {
+	p.Lock()
+	defer p.Unlock()
+	switch min := slowest; true {
+	case min < funscript.SpeedLimitMin:
+		p.speedMin = funscript.SpeedLimitMin
+	case min > funscript.SpeedLimitMax:
+		p.speedMin = funscript.SpeedLimitMax
+	default:
+		p.speedMin = min
+	}
+	switch max := fastest; true {
+	case max > funscript.SpeedLimitMax:
+		p.speedMax = funscript.SpeedLimitMax
+	case max < funscript.SpeedLimitMin:
+		p.speedMax = funscript.SpeedLimitMin
+	default:
+		p.speedMax = max
+	}
+}
+
+// action returns the action for message m moving from prev, ok is false when
+// there is no need to move. The caller must hold the lock.
+func (p *Player) action(m Message, prev *move) (a protocol.Action, ok bool) {
+	if p.inverted {
+		m.Position = 100 - m.Position
+	}
+	if m.Speed > 0 {
+		a.Position = limit(m.Position, p.positionMin, p.positionMax)
+		if a.Position > 99 {
+			a.Position = 99
+		}
+		a.Speed = limit(m.Speed, p.speedMin, p.speedMax)
+		return a, true
+	}
+
+	r := funscript.Range(p.positionMax - p.positionMin)
+	a.Position = p.positionMin + r.Position(m.Position)
+	a.Speed = p.speedMin
+	if prev != nil && prev.timed && m.Time > prev.time {
+		if a.Position == prev.position {
+			return a, false
+		}
+		dist := a.Position - prev.position
+		if dist < 0 {
+			dist = -dist
+		}
+		dur := time.Duration(m.Time-prev.time) * time.Millisecond
+		a.Speed = limit(funscript.Speed(dist, dur), p.speedMin,
+			p.speedMax)
+	}
+	return a, true
+}
+
+// playbackLoop sends the pushed messages as actions to out, waiting at least
+// the Funscript threshold between actions.
+func (p *Player) playbackLoop(out chan<- protocol.Action, notify <-chan struct{}, stop <-chan struct{}) {
+	defer func() {
+		p.wg.Done()
+		close(out)
+	}()
+
+	var (
+		prev     *move
+		lastMove time.Time
+		timer    = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-notify:
+		case <-timer.C:
+		}
+
+		if wait := funscript.Threshold - time.Since(lastMove); wait > 0 {
+			// Moved too recently, merge with messages that arrive
+			// in the meantime.
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			continue
+		}
+
+		p.Lock()
+		m := p.next
+		p.next = nil
+		var (
+			a  protocol.Action
+			ok bool
+		)
+		if m != nil {
+			a, ok = p.action(*m, prev)
+		}
+		p.Unlock()
+		if m == nil {
+			continue
+		}
+		if ok {
+			select {
+			case out <- a:
+			case <-stop:
+				return
+			}
+			lastMove = time.Now()
+		}
+		prev = &move{
+			time:     m.Time,
+			position: a.Position,
+			timed:    m.Speed == 0,
+		}
+	}
+}
+
+// limit returns v within min and max.
+func limit(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// PushJSON reads JSON encoded messages from r and pushes them to p. It
+// returns when r is closed or an invalid message is read.
+func PushJSON(r io.Reader, p *Player) error {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	for {
+		var m Message
+		if err := d.Decode(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return ErrInvalidMessage
+		}
+		if err := p.Push(m); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package live
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+func TestAction(t *testing.T) {
+	p := NewPlayer()
+	cases := []struct {
+		Message  Message
+		Prev     *move
+		Action   protocol.Action
+		Moves    bool
+		Limiting func()
+	}{
+		{ // First timed position
+			Message: Message{Position: 100},
+			Action:  protocol.Action{Position: 95, Speed: 20},
+			Moves:   true,
+		},
+		{ // Speed calculated from the previous position
+			Message: Message{Position: 0, Time: 400},
+			Prev:    &move{time: 0, position: 95, timed: true},
+			Action: protocol.Action{
+				Position: 5,
+				Speed: funscript.Speed(90,
+					time.Millisecond*400),
+			},
+			Moves: true,
+		},
+		{ // Speed limited
+			Message: Message{Position: 0, Time: 10},
+			Prev:    &move{time: 0, position: 95, timed: true},
+			Action:  protocol.Action{Position: 5, Speed: 80},
+			Moves:   true,
+		},
+		{ // Time starting over
+			Message: Message{Position: 0, Time: 100},
+			Prev:    &move{time: 400, position: 95, timed: true},
+			Action:  protocol.Action{Position: 5, Speed: 20},
+			Moves:   true,
+		},
+		{ // Same position
+			Message: Message{Position: 100, Time: 400},
+			Prev:    &move{time: 0, position: 95, timed: true},
+			Moves:   false,
+		},
+		{ // Raw move within limits
+			Message: Message{Position: 100, Speed: 99},
+			Prev:    &move{time: 0, position: 95, timed: true},
+			Action:  protocol.Action{Position: 95, Speed: 80},
+			Moves:   true,
+		},
+		{ // Raw move with custom limits
+			Message: Message{Position: 100, Speed: 10},
+			Action:  protocol.Action{Position: 99, Speed: 30},
+			Moves:   true,
+			Limiting: func() {
+				p.LimitPosition(0, 100)
+				p.LimitSpeed(30, 50)
+			},
+		},
+		{ // Inverted
+			Message: Message{Position: 100, Speed: 40},
+			Action:  protocol.Action{Position: 0, Speed: 40},
+			Moves:   true,
+			Limiting: func() {
+				p.Invert(true)
+			},
+		},
+	}
+	for i, c := range cases {
+		if c.Limiting != nil {
+			c.Limiting()
+		}
+		a, ok := p.action(c.Message, c.Prev)
+		if ok != c.Moves {
+			t.Errorf("case %d: want moves %t, got %t", i, c.Moves, ok)
+			continue
+		}
+		if ok && a != c.Action {
+			t.Errorf("case %d: want %v, got %v", i, c.Action, a)
+		}
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	p := NewPlayer()
+	// Positions pushed before playback starts are moved to
+	if err := p.Push(Message{Position: 0, Time: 0}); err != nil {
+		t.Fatal(err)
+	}
+	out := p.Play()
+
+	starttime := time.Now()
+	for _, m := range []Message{
+		{Position: 50, Time: 10},
+		{Position: 100, Time: 20},
+	} {
+		time.Sleep(time.Millisecond * 10)
+		if err := p.Push(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first position is moved to right away, the others are merged
+	// until the threshold has passed.
+	a := <-out
+	if a.Position != funscript.PositionMin {
+		t.Errorf("want first position %d, got %d",
+			funscript.PositionMin, a.Position)
+	}
+	a = <-out
+	if a.Position != funscript.PositionMax {
+		t.Errorf("want merged position %d, got %d",
+			funscript.PositionMax, a.Position)
+	}
+	if d := time.Since(starttime); d < funscript.Threshold {
+		t.Errorf("positions send faster than threshold: %s", d)
+	}
+
+	if err := p.Push(Message{Position: 101}); err != ErrInvalidMessage {
+		t.Errorf("push invalid: want ErrInvalidMessage, got %v", err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-out; ok {
+		t.Errorf("output not closed after stop")
+	}
+	if err := p.Push(Message{Position: 50}); err != ErrNotStreaming {
+		t.Errorf("push after stop: want ErrNotStreaming, got %v", err)
+	}
+}
+
+func TestPushJSON(t *testing.T) {
+	cases := []struct {
+		Input string
+		Err   error
+	}{
+		{`{"pos":10,"at":0}`, nil},
+		{"{\"pos\":10,\"at\":0}\n{\"pos\":90,\"at\":300}\n", nil},
+		{`{"pos":50,"spd":40}`, nil},
+		{`{"pos":101}`, ErrInvalidMessage},
+		{`{"pos":10,"at":-1}`, ErrInvalidMessage},
+		{`{"pos":10,"speed":50}`, ErrInvalidMessage},
+		{`pos=10`, ErrInvalidMessage},
+	}
+	for _, c := range cases {
+		p := NewPlayer()
+		out := p.Play()
+		go func() {
+			for range out {
+			}
+		}()
+		if err := PushJSON(bytes.NewBufferString(c.Input), p); err != c.Err {
+			t.Errorf("%q: want error %v, got %v", c.Input, c.Err, err)
+		}
+		p.Stop()
+	}
+}
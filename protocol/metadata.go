@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata describes a script and the video it belongs to.
+type Metadata struct {
+	Title       string     `json:"title,omitempty"`
+	Creator     string     `json:"creator,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Type        string     `json:"type,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Performers  []string   `json:"performers,omitempty"`
+	Duration    int        `json:"duration,omitempty"` // in seconds
+	License     string     `json:"license,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	ScriptURL   string     `json:"script_url,omitempty"`
+	VideoURL    string     `json:"video_url,omitempty"`
+	Chapters    []Chapter  `json:"chapters,omitempty"`
+	Bookmarks   []Bookmark `json:"bookmarks,omitempty"`
+}
+
+// Chapter is a named section of a script.
+type Chapter struct {
+	Name  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Bookmark is a named point in a script.
+type Bookmark struct {
+	Name string
+	Time time.Duration
+}
+
+// errTimestamp is returned when a timestamp can not be parsed.
+var errTimestamp = errors.New("invalid timestamp")
+
+// formatTimestamp returns d as HH:MM:SS.mmm.
+func formatTimestamp(d time.Duration) string {
+	ms := d.Nanoseconds() / 1e6
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60,
+		ms/1000%60, ms%1000)
+}
+
+// parseTimestamp parses a [[HH:]MM:]SS[.mmm] timestamp.
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, errTimestamp
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 {
+		return 0, errTimestamp
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	for i, unit := range []time.Duration{time.Minute, time.Hour} {
+		if len(parts) < i+2 {
+			break
+		}
+		n, err := strconv.Atoi(parts[len(parts)-2-i])
+		if err != nil || n < 0 {
+			return 0, errTimestamp
+		}
+		d += time.Duration(n) * unit
+	}
+	return d.Round(time.Millisecond), nil
+}
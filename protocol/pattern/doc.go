@@ -0,0 +1,41 @@
+/*
+Package pattern generates movement without a script.
+
+Patterns are strokes generated from a waveform with a tempo and depth that can
+be changed while playing. The pattern format is a JSON encoded object:
+
+  {
+    "waveform": <waveform>,
+    "tempo": <tempo>,
+    "depth": <depth>,
+    "duration": <duration>,
+    "seed": <seed>,
+    "maxtempo": <maxtempo>,
+    "ramp": <ramp>,
+    "hold": <hold>,
+    "rest": <rest>
+  }
+
+  waveform: string, shape of the strokes (see below)
+  tempo   : integer, strokes (up and down) per minute 1-300
+  depth   : integer, length of the strokes in percent 1-100
+  duration: integer, time in ms to play, 0 plays until stopped (optional)
+  seed    : integer, seed for the random waveform (optional)
+  maxtempo: integer, tempo at the top of the edging ramp (edging only)
+  ramp    : integer, time in ms to go from tempo to maxtempo (edging only)
+  hold    : integer, time in ms to stay at maxtempo (edging only)
+  rest    : integer, time in ms to rest at the bottom (edging only)
+
+Waveforms
+
+  sine    : smooth strokes slowing down at the top and bottom
+  sawtooth: slow up and fast down strokes
+  square  : fast strokes pausing at the top and bottom
+  random  : random walk between the top and bottom of the stroke
+  edging  : strokes speeding up from tempo to maxtempo, holding maxtempo
+            and resting before starting over
+
+The strokes are centered within the position range of the player, a depth of
+100 uses the full range.
+*/
+package pattern
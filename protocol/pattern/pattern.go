@@ -0,0 +1,231 @@
+package pattern
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+// Waveforms that can be generated.
+const (
+	Sine     = "sine"
+	Sawtooth = "sawtooth"
+	Square   = "square"
+	Random   = "random"
+	Edging   = "edging"
+)
+
+// Tempo and depth limits.
+const (
+	TempoMin = 1
+	TempoMax = 300
+	DepthMin = 1
+	DepthMax = 100
+)
+
+// segmentMin is the shortest segment that is generated. It is above the
+// minimal interval of the device safety limits (25ms) so moves are not
+// dropped by the safety governor, even when a timer fires a little early.
+const segmentMin = time.Millisecond * 40
+
+// ErrOutOfRange is returned when the tempo or depth is outside of the
+// supported range.
+var ErrOutOfRange = errors.New("tempo or depth out of range")
+
+// Pattern describes the movement to generate.
+type Pattern struct {
+	Waveform string `json:"waveform"`
+	Tempo    int    `json:"tempo"`
+	Depth    int    `json:"depth"`
+	Duration int    `json:"duration,omitempty"`
+	Seed     int64  `json:"seed,omitempty"`
+
+	MaxTempo int `json:"maxtempo,omitempty"`
+	Ramp     int `json:"ramp,omitempty"`
+	Hold     int `json:"hold,omitempty"`
+	Rest     int `json:"rest,omitempty"`
+}
+
+// Validate checks if the pattern can be generated.
+func (p Pattern) Validate() error {
+	switch p.Waveform {
+	case Sine, Sawtooth, Square, Random:
+	case Edging:
+		if p.MaxTempo < TempoMin || p.MaxTempo > TempoMax {
+			return fmt.Errorf("maxtempo must be %d-%d", TempoMin,
+				TempoMax)
+		}
+		if p.Ramp < 0 || p.Hold < 0 || p.Rest < 0 {
+			return errors.New("ramp, hold and rest must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown waveform %q", p.Waveform)
+	}
+	if err := validateTune(p.Tempo, p.Depth); err != nil {
+		return err
+	}
+	if p.Duration < 0 {
+		return errors.New("duration must be positive")
+	}
+	return nil
+}
+
+// validateTune checks the tempo and depth.
+func validateTune(tempo, depth int) error {
+	if tempo < TempoMin || tempo > TempoMax ||
+		depth < DepthMin || depth > DepthMax {
+		return ErrOutOfRange
+	}
+	return nil
+}
+
+// Load returns a player for the pattern in r.
+func Load(r io.Reader) (protocol.Player, error) {
+	var p Pattern
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return NewPlayer(p), nil
+}
+
+// segment is a move to a position that takes dur to complete.
+type segment struct {
+	Position int
+	Duration time.Duration
+	Speed    int // 0 calculates the speed from the distance and duration
+}
+
+// generator creates the segments of a pattern.
+type generator struct {
+	pattern Pattern
+	rnd     *rand.Rand
+	step    int     // segment number within the stroke
+	phase   float64 // part of the sine stroke that has been generated
+	last    int     // last position
+}
+
+// newGenerator returns a generator for p.
+func newGenerator(p Pattern) *generator {
+	seed := p.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &generator{
+		pattern: p,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// next returns the segment that starts at elapsed time, using tempo and
+// depth within the position range low-high.
+func (g *generator) next(elapsed time.Duration, tempo, depth, low, high int) segment {
+	span := depth * (high - low) / 100
+	bottom := low + (high-low-span)/2
+	top := bottom + span
+
+	if g.pattern.Waveform == Edging {
+		var rest time.Duration
+		tempo, rest = g.edgingTempo(elapsed, tempo)
+		if rest > 0 {
+			g.step = 0
+			return g.move(bottom, rest, 0)
+		}
+	}
+	period := time.Minute / time.Duration(tempo)
+	defer func() { g.step++ }()
+
+	switch g.pattern.Waveform {
+	case Sine:
+		// Fewer steps per stroke at high tempos to keep the segments
+		// longer than segmentMin.
+		steps := 8
+		for steps > 2 && period/time.Duration(steps) < segmentMin {
+			steps /= 2
+		}
+		g.phase += 1 / float64(steps)
+		if g.phase >= 1 {
+			g.phase--
+		}
+		pos := bottom + int(math.Round(float64(span)*
+			(1-math.Cos(2*math.Pi*g.phase))/2))
+		return g.move(pos, period/time.Duration(steps), 0)
+	case Sawtooth:
+		if g.step%2 == 0 {
+			return g.move(top, period*4/5, 0)
+		}
+		return g.move(bottom, period/5, 0)
+	case Square:
+		// Move in half of the segment and hold the position for the
+		// rest.
+		spd := funscript.Speed(span, period/4)
+		if g.step%2 == 0 {
+			return g.move(top, period/2, spd)
+		}
+		return g.move(bottom, period/2, spd)
+	case Random:
+		pos := bottom
+		if span > 0 {
+			pos = g.last + g.rnd.Intn(span+1) - span/2
+		}
+		if pos < bottom {
+			pos = bottom
+		} else if pos > top {
+			pos = top
+		}
+		return g.move(pos, period/2, 0)
+	default: // Edging
+		if g.step%2 == 0 {
+			return g.move(top, period/2, 0)
+		}
+		return g.move(bottom, period/2, 0)
+	}
+}
+
+// move returns a segment to pos and remembers the position. Segments are at
+// least segmentMin long.
+func (g *generator) move(pos int, dur time.Duration, spd int) segment {
+	if dur < segmentMin {
+		dur = segmentMin
+	}
+	g.last = pos
+	return segment{
+		Position: pos,
+		Duration: dur,
+		Speed:    spd,
+	}
+}
+
+// edgingTempo returns the tempo at elapsed time in the edging program, or
+// the remaining time to rest.
+func (g *generator) edgingTempo(elapsed time.Duration, tempo int) (int, time.Duration) {
+	p := g.pattern
+	ramp := time.Duration(p.Ramp) * time.Millisecond
+	hold := time.Duration(p.Hold) * time.Millisecond
+	rest := time.Duration(p.Rest) * time.Millisecond
+	cycle := ramp + hold + rest
+	if cycle <= 0 {
+		return tempo, 0
+	}
+	t := elapsed % cycle
+	switch {
+	case t < ramp:
+		return tempo + int(float64(p.MaxTempo-tempo)*
+			float64(t)/float64(ramp)), 0
+	case t < ramp+hold:
+		return p.MaxTempo, 0
+	default:
+		return tempo, cycle - t
+	}
+}
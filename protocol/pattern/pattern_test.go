@@ -0,0 +1,236 @@
+package pattern
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+func TestLoad(t *testing.T) {
+	valid := []string{
+		`{"waveform":"sine","tempo":60,"depth":100}`,
+		`{"waveform":"random","tempo":30,"depth":50,"seed":1,"duration":1000}`,
+		`{"waveform":"edging","tempo":30,"depth":80,"maxtempo":120,"ramp":60000,"hold":10000,"rest":20000}`,
+	}
+	for _, s := range valid {
+		p, err := Load(bytes.NewBufferString(s))
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if _, ok := p.(protocol.Tunable); !ok {
+			t.Errorf("player is not tunable")
+		}
+	}
+	invalid := []string{
+		`{"waveform":"triangle","tempo":60,"depth":100}`,
+		`{"waveform":"sine","tempo":0,"depth":100}`,
+		`{"waveform":"sine","tempo":60,"depth":101}`,
+		`{"waveform":"edging","tempo":60,"depth":100}`,
+		`{"waveform":"sine","tempo":60,"depth":100,"actions":[]}`,
+		`[{"at":100,"pos":50,"spd":30}]`,
+	}
+	for _, s := range invalid {
+		if _, err := Load(bytes.NewBufferString(s)); err == nil {
+			t.Errorf("%s: loaded invalid pattern", s)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	period := time.Second // tempo 60
+	cases := []struct {
+		Pattern   Pattern
+		Positions []int
+		Durations []time.Duration
+		Speeds    []int
+	}{
+		{
+			Pattern:   Pattern{Waveform: Sine},
+			Positions: []int{14, 49, 85, 99, 85, 50, 14, 0},
+			Durations: []time.Duration{period / 8},
+		},
+		{
+			Pattern:   Pattern{Waveform: Sawtooth},
+			Positions: []int{99, 0, 99},
+			Durations: []time.Duration{period * 4 / 5, period / 5},
+		},
+		{
+			Pattern:   Pattern{Waveform: Square},
+			Positions: []int{99, 0},
+			Durations: []time.Duration{period / 2},
+			Speeds:    []int{funscript.Speed(99, period/4)},
+		},
+	}
+	for i, c := range cases {
+		g := newGenerator(c.Pattern)
+		for j, want := range c.Positions {
+			s := g.next(0, 60, 100, 0, 99)
+			if s.Position != want {
+				t.Errorf("case %d step %d: want position %d, got %d",
+					i, j, want, s.Position)
+			}
+			if d := c.Durations[j%len(c.Durations)]; s.Duration != d {
+				t.Errorf("case %d step %d: want duration %s, got %s",
+					i, j, d, s.Duration)
+			}
+			if len(c.Speeds) > 0 && s.Speed != c.Speeds[j%len(c.Speeds)] {
+				t.Errorf("case %d step %d: unexpected speed %d",
+					i, j, s.Speed)
+			}
+		}
+	}
+}
+
+func TestGeneratorSegmentMin(t *testing.T) {
+	for _, w := range []string{Sine, Sawtooth, Square, Random} {
+		g := newGenerator(Pattern{Waveform: w, Seed: 42})
+		var elapsed time.Duration
+		for i := 0; i < 16; i++ {
+			s := g.next(elapsed, TempoMax, 100, 0, 99)
+			if s.Duration < segmentMin {
+				t.Errorf("%s step %d: duration %s shorter than %s",
+					w, i, s.Duration, segmentMin)
+			}
+			elapsed += s.Duration
+		}
+	}
+
+	// Sine strokes at the highest tempo still reach the top and bottom.
+	g := newGenerator(Pattern{Waveform: Sine})
+	var positions []int
+	for i := 0; i < 4; i++ {
+		positions = append(positions, g.next(0, TempoMax, 100, 0, 99).Position)
+	}
+	if want := []int{49, 99, 50, 0}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("sine at tempo %d, want %v, got %v", TempoMax, want,
+			positions)
+	}
+
+	// Square waves move slower when the stroke is shorter.
+	g = newGenerator(Pattern{Waveform: Square})
+	full := g.next(0, 60, 100, 0, 99).Speed
+	g = newGenerator(Pattern{Waveform: Square})
+	if short := g.next(0, 60, 20, 0, 99).Speed; short >= full {
+		t.Errorf("square speed for depth 20 (%d) not below depth 100 (%d)",
+			short, full)
+	}
+}
+
+func TestGeneratorDepth(t *testing.T) {
+	g := newGenerator(Pattern{Waveform: Random, Seed: 42})
+	g.last = 50
+	for i := 0; i < 100; i++ {
+		s := g.next(0, 60, 50, 0, 99)
+		if s.Position < 25 || s.Position > 74 {
+			t.Fatalf("position %d outside of depth", s.Position)
+		}
+	}
+}
+
+func TestEdging(t *testing.T) {
+	g := newGenerator(Pattern{
+		Waveform: Edging,
+		MaxTempo: 120,
+		Ramp:     1000,
+		Hold:     1000,
+		Rest:     1000,
+	})
+	if s := g.next(0, 60, 100, 0, 99); s.Duration != time.Second/2 {
+		t.Errorf("unexpected start duration %s", s.Duration)
+	}
+	if s := g.next(time.Millisecond*1500, 60, 100, 0, 99); s.Duration != time.Second/4 {
+		t.Errorf("unexpected max tempo duration %s", s.Duration)
+	}
+	s := g.next(time.Millisecond*2200, 60, 100, 0, 99)
+	if s.Position != 0 || s.Duration != time.Millisecond*800 {
+		t.Errorf("not resting: %+v", s)
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	p := NewPlayer(Pattern{
+		Waveform: Sawtooth,
+		Tempo:    300,
+		Depth:    100,
+		Duration: 1000,
+	})
+	if err := p.Tune(301, 0); err != ErrOutOfRange {
+		t.Errorf("tune out of range: want ErrOutOfRange, got %v", err)
+	}
+	var actions []protocol.Action
+	out := p.Play()
+	done := make(chan struct{})
+	go func() {
+		for a := range out {
+			actions = append(actions, a)
+		}
+		close(done)
+	}()
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tune(0, 50); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	// 5 strokes (up and down) per second
+	if len(actions) < 9 || len(actions) > 11 {
+		t.Errorf("expected ~10 actions, got %d", len(actions))
+	}
+	for _, a := range actions {
+		if a.Speed < funscript.SpeedLimitMin ||
+			a.Speed > funscript.SpeedLimitMax {
+			t.Errorf("speed out of range: %v", a)
+		}
+	}
+	if last := actions[len(actions)-1]; last.Position < 24 ||
+		last.Position > 75 {
+		t.Errorf("depth not tuned: %v", last)
+	}
+
+	out = p.Play()
+	done = make(chan struct{})
+	go func() {
+		for range out {
+		}
+		close(done)
+	}()
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestPlayerPauseResume(t *testing.T) {
+	// 1 stroke per second, every move takes 500ms
+	p := NewPlayer(Pattern{
+		Waveform: Sawtooth,
+		Tempo:    60,
+		Depth:    100,
+	})
+	out := p.Play()
+	defer p.Stop()
+	<-out
+	time.Sleep(time.Millisecond * 100)
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 500)
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	resumed := time.Now()
+	<-out
+	// The rest of the move (400ms) is played before the next one
+	if d := time.Since(resumed); d < time.Millisecond*300 {
+		t.Errorf("next move %s after resume, want the rest of the move", d)
+	}
+}
@@ -0,0 +1,241 @@
+package pattern
+
+import (
+	"sync"
+	"time"
+
+	"github.com/funjack/launchcontrol/protocol"
+	"github.com/funjack/launchcontrol/protocol/funscript"
+)
+
+type command int
+
+const (
+	cmdStop   command = iota // stop playback
+	cmdPause                 // pause playback
+	cmdResume                // resume playback
+
+	commandTimeout = time.Second
+)
+
+// Player generates and plays a pattern. The tempo and depth can be tuned
+// while playing.
+type Player struct {
+	sync.Mutex
+
+	pattern  Pattern
+	tempo    int
+	depth    int
+	latency  time.Duration
+	posMin   int
+	posMax   int
+	speedMin int
+	speedMax int
+	inverted bool
+
+	wg   sync.WaitGroup
+	ctrl chan command
+}
+
+// NewPlayer returns a player for pattern p.
+func NewPlayer(p Pattern) *Player {
+	return &Player{
+		pattern:  p,
+		tempo:    p.Tempo,
+		depth:    p.Depth,
+		posMin:   0,
+		posMax:   99,
+		speedMin: funscript.SpeedLimitMin,
+		speedMax: funscript.SpeedLimitMax,
+		ctrl:     make(chan command),
+	}
+}
+
+// Play starts generating the pattern from the start.
+func (p *Player) Play() <-chan protocol.Action {
+	// Only play one pattern at a time
+	p.wg.Wait()
+	p.wg.Add(1)
+	out := make(chan protocol.Action)
+	go p.playbackLoop(out, p.ctrl)
+	return out
+}
+
+// Stop stops playback.
+func (p *Player) Stop() error {
+	return p.sendCommand(cmdStop)
+}
+
+// Pause implements the Pausable interface.
+func (p *Player) Pause() error {
+	return p.sendCommand(cmdPause)
+}
+
+// Resume implements the Pausable interface.
+func (p *Player) Resume() error {
+	return p.sendCommand(cmdResume)
+}
+
+// Tune implements the Tunable interface. A tempo or depth of 0 keeps the
+// current value.
+func (p *Player) Tune(tempo, depth int) error {
+	p.Lock()
+	defer p.Unlock()
+	if tempo == 0 {
+		tempo = p.tempo
+	}
+	if depth == 0 {
+		depth = p.depth
+	}
+	if err := validateTune(tempo, depth); err != nil {
+		return err
+	}
+	p.tempo, p.depth = tempo, depth
+	return nil
+}
+
+// Latency implements the LatencyCalibrator interface, playback starts after
+// the latency.
+func (p *Player) Latency(t time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+	p.latency = t
+}
+
+// LimitPosition implements the PositionLimiter interface, strokes are
+// centered within the range.
+func (p *Player) LimitPosition(low, high int) {
+	if low >= high || low < 0 || high > 100 {
+		// Ignore invalid config
+		return
+	}
+	if high > 99 {
+		high = 99
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.posMin, p.posMax = low, high
+}
+
+// Invert implements the Inverter interface, positions are mirrored within the
+// position limits.
+func (p *Player) Invert(inverted bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.inverted = inverted
+}
+
+// LimitSpeed implements the SpeedLimiter interface.
+func (p *Player) LimitSpeed(slow, fast int) {
+	if slow >= fast {
+		// Ignore invalid config
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.speedMin, p.speedMax = slow, fast
+}
+
+// sendCommand to the playbackLoop with a timeout.
+func (p *Player) sendCommand(c command) error {
+	select {
+	case p.ctrl <- c:
+		return nil
+	case <-time.After(commandTimeout):
+		return protocol.ErrTimeout
+	}
+}
+
+// action returns the action for segment s starting at position from.
+func (p *Player) action(s segment, from int) protocol.Action {
+	p.Lock()
+	defer p.Unlock()
+	spd := s.Speed
+	if spd == 0 {
+		dist := s.Position - from
+		if dist < 0 {
+			dist = -dist
+		}
+		spd = funscript.Speed(dist, s.Duration)
+	}
+	if spd < p.speedMin {
+		spd = p.speedMin
+	} else if spd > p.speedMax {
+		spd = p.speedMax
+	}
+	pos := s.Position
+	if p.inverted {
+		pos = p.posMin + p.posMax - pos
+	}
+	return protocol.Action{
+		Position: pos,
+		Speed:    spd,
+	}
+}
+
+// playbackLoop generates the pattern to out and can be controlled using
+// ctrl.
+func (p *Player) playbackLoop(out chan<- protocol.Action, ctrl <-chan command) {
+	defer func() {
+		p.wg.Done()
+		close(out)
+	}()
+
+	p.Lock()
+	gen := newGenerator(p.pattern)
+	gen.last = p.posMin
+	duration := time.Duration(p.pattern.Duration) * time.Millisecond
+	next := time.NewTimer(p.latency)
+	due := time.Now().Add(p.latency)
+	p.Unlock()
+	defer next.Stop()
+
+	var (
+		elapsed   time.Duration // generated pattern time
+		paused    bool
+		remaining time.Duration // time left of the segment when paused
+	)
+	for {
+		select {
+		case cmd := <-ctrl:
+			switch cmd {
+			case cmdStop:
+				return
+			case cmdPause:
+				if !paused {
+					paused = true
+					remaining = 0
+					if next.Stop() {
+						remaining = time.Until(due)
+					} else {
+						select {
+						case <-next.C:
+						default:
+						}
+					}
+				}
+			case cmdResume:
+				if paused {
+					paused = false
+					due = time.Now().Add(remaining)
+					next.Reset(remaining)
+				}
+			}
+		case <-next.C:
+			if duration > 0 && elapsed >= duration {
+				return
+			}
+			p.Lock()
+			tempo, depth := p.tempo, p.depth
+			low, high := p.posMin, p.posMax
+			p.Unlock()
+
+			from := gen.last
+			s := gen.next(elapsed, tempo, depth, low, high)
+			out <- p.action(s, from)
+			elapsed += s.Duration
+			due = time.Now().Add(s.Duration)
+			next.Reset(s.Duration)
+		}
+	}
+}
@@ -37,6 +37,8 @@ type control struct {
 type TimedActionsPlayer struct {
 	// Script that the player will use.
 	Script []TimedAction
+	// Meta is the description of the script (optional.)
+	Meta *Metadata
 
 	wg   sync.WaitGroup
 	ctrl chan control
@@ -44,6 +46,20 @@ type TimedActionsPlayer struct {
 	latency        time.Duration
 	posLimitFunc   func(int) int
 	speedLimitFunc func(int) int
+	posLow         int
+	posHigh        int
+	inverted       bool
+
+	clockMux sync.Mutex
+	clock    clock
+}
+
+// clock is the playback position of the playbackLoop.
+type clock struct {
+	playing       bool
+	paused        bool
+	startTime     time.Time     // time playback started/resumed
+	startPosition time.Duration // timecode where playback started
 }
 
 // NewTimedActionsPlayer returns a new TimedActionsPlayer.
@@ -52,6 +68,8 @@ func NewTimedActionsPlayer() *TimedActionsPlayer {
 		ctrl:           make(chan control),
 		posLimitFunc:   func(p int) int { return p },
 		speedLimitFunc: func(s int) int { return s },
+		posLow:         0,
+		posHigh:        100,
 	}
 }
 
@@ -78,6 +96,7 @@ func (ta *TimedActionsPlayer) LimitPosition(low, high int) {
 		// Ignore invalid config
 		return
 	}
+	ta.posLow, ta.posHigh = low, high
 	ta.posLimitFunc = func(p int) int {
 		if p < low {
 			return low
@@ -108,6 +127,12 @@ func (ta *TimedActionsPlayer) LimitSpeed(slow, fast int) {
 	}
 }
 
+// Invert implements the Inverter interface, positions are mirrored within
+// the position limits.
+func (ta *TimedActionsPlayer) Invert(inverted bool) {
+	ta.inverted = inverted
+}
+
 // sendCommand to the playbackLoop with a timeout.
 func (ta *TimedActionsPlayer) sendCommand(c control) error {
 	select {
@@ -154,10 +179,36 @@ func (ta *TimedActionsPlayer) Dump() (TimedActions, error) {
 	return s, nil
 }
 
+// Position implements the Trackable interface.
+func (ta *TimedActionsPlayer) Position() time.Duration {
+	ta.clockMux.Lock()
+	defer ta.clockMux.Unlock()
+	switch {
+	case !ta.clock.playing:
+		return 0
+	case ta.clock.paused:
+		return ta.clock.startPosition
+	}
+	return calcPosition(ta.clock.startTime, ta.clock.startPosition)
+}
+
+// setClock updates the playback position.
+func (ta *TimedActionsPlayer) setClock(c clock) {
+	ta.clockMux.Lock()
+	defer ta.clockMux.Unlock()
+	ta.clock = c
+}
+
+// Metadata implements the Describable interface.
+func (ta *TimedActionsPlayer) Metadata() *Metadata {
+	return ta.Meta
+}
+
 // playbackLoop will play the loaded script to out and can be controlled using
 // ctrl.
 func (ta *TimedActionsPlayer) playbackLoop(out chan<- Action, ctrl <-chan control) {
 	defer func() {
+		ta.setClock(clock{})
 		ta.wg.Done()
 		close(out)
 	}()
@@ -170,6 +221,13 @@ func (ta *TimedActionsPlayer) playbackLoop(out chan<- Action, ctrl <-chan contro
 	)
 
 	for cursor < len(ta.Script) {
+		ta.setClock(clock{
+			playing:       true,
+			paused:        paused,
+			startTime:     startTime,
+			startPosition: startPosition,
+		})
+
 		a := ta.Script[cursor]
 		if a.Time < startPosition {
 			cursor++
@@ -209,10 +267,7 @@ func (ta *TimedActionsPlayer) playbackLoop(out chan<- Action, ctrl <-chan contro
 			}
 		case <-nextEventTime:
 			if !paused {
-				out <- Action{
-					Position: ta.posLimitFunc(a.Position),
-					Speed:    ta.speedLimitFunc(a.Speed),
-				}
+				out <- ta.limit(a.Action)
 				cursor++
 			}
 		}
@@ -220,6 +275,20 @@ func (ta *TimedActionsPlayer) playbackLoop(out chan<- Action, ctrl <-chan contro
 	}
 }
 
+// limit applies the position and speed limits and the inversion to stroke
+// actions. Actions of other axes are returned as is.
+func (ta *TimedActionsPlayer) limit(a Action) Action {
+	if !a.IsStroke() {
+		return a
+	}
+	a.Position = ta.posLimitFunc(a.Position)
+	if ta.inverted {
+		a.Position = ta.posLow + ta.posHigh - a.Position
+	}
+	a.Speed = ta.speedLimitFunc(a.Speed)
+	return a
+}
+
 // calcPosition will return the current timecode in the script based on start
 // time and starting position.
 func calcPosition(startTime time.Time, startPosition time.Duration) time.Duration {
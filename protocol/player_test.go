@@ -296,3 +296,70 @@ func TestDump(t *testing.T) {
 			len(script), len(actions))
 	}
 }
+
+func TestLimitAxes(t *testing.T) {
+	p := NewTimedActionsPlayer()
+	p.LimitPosition(10, 20)
+	p.LimitSpeed(30, 40)
+
+	stroke := p.limit(Action{Position: 50, Speed: 90})
+	if stroke.Position != 20 || stroke.Speed != 40 {
+		t.Errorf("stroke not limited: %v", stroke)
+	}
+	roll := Action{Position: 50, Speed: 90, Axis: AxisRoll}
+	if got := p.limit(roll); got != roll {
+		t.Errorf("axis limited: want %v, got %v", roll, got)
+	}
+
+	p.Invert(true)
+	for _, c := range []struct{ Position, Want int }{
+		{50, 10}, {15, 15}, {0, 20},
+	} {
+		got := p.limit(Action{Position: c.Position, Speed: 30})
+		if got.Position != c.Want {
+			t.Errorf("inverted %d, want %d, got %d", c.Position, c.Want,
+				got.Position)
+		}
+	}
+	if got := p.limit(roll); got != roll {
+		t.Errorf("axis inverted: want %v, got %v", roll, got)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	p := NewTimedActionsPlayer()
+	p.Script = script
+	if pos := p.Position(); pos != 0 {
+		t.Errorf("position before play: want 0, got %s", pos)
+	}
+
+	out := p.Play()
+	go func() {
+		for range out {
+		}
+	}()
+	if err := p.Skip(time.Millisecond * 100); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	want := time.Millisecond * 120
+	if pos := p.Position(); !defaultTimeTolerance.roughlyEqual(pos, want) {
+		t.Errorf("paused position: want %s, got %s", want, pos)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if pos := p.Position(); !defaultTimeTolerance.roughlyEqual(pos, want) {
+		t.Errorf("position moved while paused: want %s, got %s", want, pos)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	if pos := p.Position(); pos != 0 {
+		t.Errorf("position after stop: want 0, got %s", pos)
+	}
+}
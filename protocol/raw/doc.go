@@ -10,7 +10,8 @@ The file format is a JSON encoded array of timed actions:
     {
       "at": <time>,
       "pos": <position>,
-      "spd": <speed>
+      "spd": <speed>,
+      "axis": <axis>
     },
     ...
   ]
@@ -18,6 +19,8 @@ The file format is a JSON encoded array of timed actions:
   time    : integer, time in ms when the action is executed
   position: integer, position to move to 0-99 (bottom ... top)
   speed   : integer, speed to move at 20-99 (slow ... fast)
+  axis    : string, T-Code name of the axis to move (eg R1), the stroke when
+            not set (optional)
 
 The raw format uses the same values as the BLE protocol, giving the script the
 biggest amount of control but with great power comes great responsibility ;-)
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package raw
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/funjack/launchcontrol/protocol"
+)
+
+func FuzzLoad(f *testing.F) {
+	f.Add([]byte(input))
+	f.Add([]byte(`[{"at":100,"pos":50,"spd":30,"axis":"R0"}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Load(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		actions, err := p.(protocol.Dumpable).Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := actions.Validate(); err != nil {
+			t.Fatalf("invalid actions: %v", err)
+		}
+	})
+}
@@ -7,10 +7,14 @@ import (
 	"github.com/funjack/launchcontrol/protocol"
 )
 
-// Load returns a player with the waw script loaded.
+// Load returns a player with the waw script loaded. Scripts with actions that
+// are not in time order, or with positions or speeds out of range are
+// refused.
 func Load(r io.Reader) (protocol.Player, error) {
 	p := protocol.NewTimedActionsPlayer()
 	d := json.NewDecoder(r)
-	err := d.Decode(&p.Script)
-	return p, err
+	if err := d.Decode(&p.Script); err != nil {
+		return p, err
+	}
+	return p, protocol.TimedActions(p.Script).Validate()
 }
@@ -32,3 +32,16 @@ func TestLoad(t *testing.T) {
 		t.Errorf("error loading script: %v", err)
 	}
 }
+
+func TestLoadInvalid(t *testing.T) {
+	for _, c := range []string{
+		`[{"at":200,"pos":50,"spd":30},{"at":100,"pos":70,"spd":50}]`,
+		`[{"at":-100,"pos":50,"spd":30}]`,
+		`[{"at":100,"pos":150,"spd":30}]`,
+		`[{"at":100,"pos":50,"spd":-1}]`,
+	} {
+		if _, err := Load(bytes.NewBufferString(c)); err == nil {
+			t.Errorf("%s: invalid script loaded", c)
+		}
+	}
+}
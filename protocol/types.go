@@ -2,14 +2,33 @@
 package protocol
 
 import (
+	"fmt"
 	"io"
 	"time"
 )
 
-// Action is a command that can be send to a device.
+// Axis names of multi-axis scripts, these are the T-Code axis names.
+const (
+	AxisStroke  = "L0" // up and down
+	AxisSurge   = "L1" // forward and backward
+	AxisSway    = "L2" // left and right
+	AxisTwist   = "R0" // rotation around the stroke axis
+	AxisRoll    = "R1" // rotation around the surge axis
+	AxisPitch   = "R2" // rotation around the sway axis
+	AxisVibrate = "V0" // vibration intensity
+)
+
+// Action is a command that can be send to a device. Actions without an axis
+// are moves on the stroke axis.
 type Action struct {
-	Position int `json:"pos"`
-	Speed    int `json:"spd"`
+	Position int    `json:"pos"`
+	Speed    int    `json:"spd"`
+	Axis     string `json:"axis,omitempty"`
+}
+
+// IsStroke returns true when the action moves the stroke axis.
+func (a Action) IsStroke() bool {
+	return a.Axis == "" || a.Axis == AxisStroke
 }
 
 // TimedAction wraps Action together with a timestamp.
@@ -21,6 +40,28 @@ type TimedAction struct {
 // TimedActions is an ordered list of TimeAction items.
 type TimedActions []TimedAction
 
+// Validate returns an error when the actions are not in time order or have a
+// negative time, position or speed, or a position or speed above 100.
+func (ta TimedActions) Validate() error {
+	var previous time.Duration
+	for i, a := range ta {
+		switch {
+		case a.Time < 0:
+			return fmt.Errorf("action %d: negative time", i)
+		case a.Time < previous:
+			return fmt.Errorf("action %d: not in time order", i)
+		case a.Position < 0 || a.Position > 100:
+			return fmt.Errorf("action %d: position %d out of range (0-100)",
+				i, a.Position)
+		case a.Speed < 0 || a.Speed > 100:
+			return fmt.Errorf("action %d: speed %d out of range (0-100)",
+				i, a.Speed)
+		}
+		previous = a.Time
+	}
+	return nil
+}
+
 // Loader is the interface that wraps the Load method.
 type Loader interface {
 	// Load a script from the provided reader.
@@ -46,6 +87,12 @@ type SpeedLimiter interface {
 	LimitSpeed(slowest, fastest int)
 }
 
+// Inverter wraps the Invert method, inverted players move up when the script
+// moves down.
+type Inverter interface {
+	Invert(inverted bool)
+}
+
 // LatencyCalibrator wraps the Latency method.
 type LatencyCalibrator interface {
 	Latency(t time.Duration)
@@ -75,6 +122,26 @@ type Skippable interface {
 	Skip(position time.Duration) error
 }
 
+// Trackable is a interface that wraps the position method.
+type Trackable interface {
+	// Position returns the current position/timecode in the script.
+	Position() time.Duration
+}
+
+// Tunable is a interface that wraps the tune method.
+type Tunable interface {
+	// Tune changes the tempo (strokes per minute) and depth (stroke
+	// length in percent) of the playback.
+	Tune(tempo, depth int) error
+}
+
+// Describable is a interface that wraps the metadata method.
+type Describable interface {
+	// Metadata returns the description of the script, or nil when
+	// there is none.
+	Metadata() *Metadata
+}
+
 // Dumpable is a interface thtat wraps the dump method.
 type Dumpable interface {
 	// Dump the full script as TimedActions.
@@ -0,0 +1,30 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimedActionsValidate(t *testing.T) {
+	valid := TimedActions{
+		{Time: 0, Action: Action{Position: 0, Speed: 20}},
+		{Time: time.Second, Action: Action{Position: 100, Speed: 100}},
+		{Time: time.Second, Action: Action{Position: 50, Speed: 0, Axis: AxisRoll}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid actions: %v", err)
+	}
+	cases := []TimedActions{
+		{{Time: -time.Millisecond}},
+		{{Time: time.Second}, {Time: 0}},
+		{{Action: Action{Position: 101}}},
+		{{Action: Action{Position: -1}}},
+		{{Action: Action{Speed: 101}}},
+		{{Action: Action{Speed: -1}}},
+	}
+	for i, c := range cases {
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: invalid actions accepted: %+v", i, c)
+		}
+	}
+}